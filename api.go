@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spwg/livereload/internal/livereload"
+)
+
+// sessionInfo tells clients on the same machine how to reach the control
+// API of a running session. It is written to sessionFile, in a directory
+// only the user can read, for as long as the session runs. Cert is the
+// server's certificate file when it uses TLS, which clients trust for this
+// connection even if it is self-signed.
+type sessionInfo struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+	PID   int    `json:"pid"`
+	Cert  string `json:"cert,omitempty"`
+}
+
+// sessionFile returns the path of the session file for the server on port.
+// It is kept in $XDG_RUNTIME_DIR, or else the user's cache directory, so
+// that other users can neither read it nor create it first.
+func sessionFile(port int) (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "livereload", fmt.Sprintf("session-%d.json", port)), nil
+}
+
+// writeSession writes the session file for a server on host and port,
+// served with certFile if not empty. The returned function removes it.
+func writeSession(host string, port int, token, certFile string) (func(), error) {
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	scheme := "http"
+	if certFile != "" {
+		scheme = "https"
+	}
+	info := sessionInfo{
+		URL:   scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port)),
+		Token: token,
+		PID:   os.Getpid(),
+		Cert:  certFile,
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	path, err := sessionFile(port)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	// A session that was killed leaves its file behind. Replace it with a
+	// new file rather than writing through whatever is there.
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return func() { os.Remove(path) }, nil
+}
+
+// apiClient calls the control API of a running session.
+type apiClient struct {
+	url, token string
+	cert       string // trusted certificate file, if any
+}
+
+// apiClientFlags defines the flags that locate a session on fs.
+func apiClientFlags(fs *flag.FlagSet) func() (*apiClient, error) {
+	port := fs.Int("port", 0, "`port` of the livereload server (default: from the config file, or 35729)")
+	token := fs.String("token", os.Getenv("LIVERELOAD_TOKEN"), "API `token` printed at startup (default: read from the session file)")
+	return func() (*apiClient, error) {
+		p := *port
+		if p == 0 {
+			p = defaultConfig().Port
+			if path, err := findConfig("."); err == nil && path != "" {
+				if cfg, err := resolveConfig(path, os.Getenv("LIVERELOAD_PROFILE"), os.Getenv, nil); err == nil {
+					p = cfg.Port
+				}
+			}
+		}
+		c := &apiClient{url: fmt.Sprintf("http://localhost:%d", p), token: *token}
+		path, err := sessionFile(p)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if c.token == "" {
+				return nil, fmt.Errorf("no livereload session found on port %d", p)
+			}
+			return c, nil
+		}
+		var info sessionInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		c.url = info.URL
+		c.cert = info.Cert
+		if c.token == "" {
+			c.token = info.Token
+		}
+		return c, nil
+	}
+}
+
+// call sends a request to the API and decodes the JSON response into out,
+// if not nil.
+func (c *apiClient) call(method, path string, out any) error {
+	req, err := http.NewRequest(method, c.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	client := &http.Client{Timeout: 5 * time.Second}
+	if c.cert != "" {
+		pem, err := os.ReadFile(c.cert)
+		if err != nil {
+			return err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: no certificates found", c.cert)
+		}
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out != nil {
+		return json.Unmarshal(body, out)
+	}
+	return nil
+}
+
+// setupReload defines the flags of "livereload reload", which asks a
+// running session to reload the browsers, restart or rebuild the app.
+func setupReload(fs *flag.FlagSet) func(args []string) error {
+	client := apiClientFlags(fs)
+	restart := fs.Bool("restart", false, "Restart the app instead, without building")
+	build := fs.Bool("build", false, "Rebuild and restart the app instead")
+	return func(args []string) error {
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		path := "/api/reload"
+		switch {
+		case *build:
+			path = "/api/build"
+		case *restart:
+			path = "/api/restart"
+		}
+		return c.call(http.MethodPost, path, nil)
+	}
+}
+
+// setupStatus defines the flags of "livereload status", which prints the
+// state of a running session.
+func setupStatus(fs *flag.FlagSet) func(args []string) error {
+	client := apiClientFlags(fs)
+	asJSON := fs.Bool("json", false, "Print the status as JSON")
+	return func(args []string) error {
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		var s livereload.Status
+		if err := c.call(http.MethodGet, "/api/status", &s); err != nil {
+			return err
+		}
+		if *asJSON {
+			out, err := json.MarshalIndent(s, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+		if s.PID != 0 {
+			fmt.Printf("App:      running (pid %d)\n", s.PID)
+		} else {
+			fmt.Printf("App:      not running\n")
+		}
+		if b := s.LastBuild; b != nil {
+			result := "ok"
+			if !b.OK {
+				result = "failed: " + b.Error
+			}
+			fmt.Printf("Build:    %s (%s, took %v)\n", result, b.Time.Format(time.TimeOnly), time.Duration(b.DurationMS)*time.Millisecond)
+		} else {
+			fmt.Printf("Build:    none yet\n")
+		}
+		fmt.Printf("Browsers: %d connected\n", s.Clients)
+		fmt.Printf("Watching: %s\n", strings.Join(s.WatchRoots, ", "))
+		return nil
+	}
+}
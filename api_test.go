@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spwg/livereload/internal/livereload"
+)
+
+func TestAPIClient_SessionFile(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	path, err := sessionFile(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, runtimeDir) {
+		t.Errorf("Expected the session file in $XDG_RUNTIME_DIR, got %s", path)
+	}
+	// A file left behind by an earlier session is replaced.
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"url": "http://stale.test"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	remove, err := writeSession("0.0.0.0", port, "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected session file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	fs := flag.NewFlagSet("reload", flag.ContinueOnError)
+	client := apiClientFlags(fs)
+	fs.Parse([]string{"--port", u.Port()})
+	c, err := client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.call(http.MethodPost, "/api/reload", nil); err != nil {
+		t.Fatal(err)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Expected the token from the session file, got %q", gotAuth)
+	}
+
+	remove()
+	if _, err := client(); err == nil {
+		t.Error("Expected an error once the session has ended")
+	}
+}
+
+func TestAPIClient_TLS(t *testing.T) {
+	certFile, keyFile, err := livereload.SelfSignedCert(t.TempDir(), []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	srv.StartTLS()
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	remove, err := writeSession("127.0.0.1", port, "secret", certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remove()
+
+	fs := flag.NewFlagSet("reload", flag.ContinueOnError)
+	client := apiClientFlags(fs)
+	fs.Parse([]string{"--port", u.Port()})
+	c, err := client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.call(http.MethodPost, "/api/reload", nil); err != nil {
+		t.Fatalf("Expected the session's certificate to be trusted: %v", err)
+	}
+
+	c.cert = filepath.Join(t.TempDir(), "missing.pem")
+	if err := c.call(http.MethodPost, "/api/reload", nil); err == nil {
+		t.Error("Expected an error without the certificate")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// setupCheck defines the flags of "livereload check [file]", which
+// validates the config file, and each profile in it, without building or
+// running anything. The file may be given as an argument or with --config.
+func setupCheck(fs *flag.FlagSet) func(args []string) error {
+	configPath := fs.String("config", os.Getenv("LIVERELOAD_CONFIG"), "Path to the config file (default: search upward for livereload.toml, .yaml, .yml or .json)")
+	profile := fs.String("profile", "", "Only check this profile (default: the base settings and every profile)")
+	return func(args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("unexpected argument %q", args[1])
+		}
+		path := *configPath
+		if len(args) == 1 {
+			path = args[0]
+		}
+		return runCheck(path, *profile)
+	}
+}
+
+// errInvalidConfig is returned by runCheck once it has printed what is
+// wrong with the config.
+var errInvalidConfig = errors.New("invalid configuration")
+
+func runCheck(configPath, profile string) error {
+	path := configPath
+	if path == "" {
+		found, err := findConfig(".")
+		if err != nil {
+			return err
+		}
+		if found == "" {
+			return errors.New("no config file found in this directory or its parents")
+		}
+		path = found
+	}
+
+	// Unknown keys and malformed values are reported once for the whole
+	// file rather than once per profile.
+	if _, err := readConfig(path); err != nil {
+		fmt.Printf("%s:\n  %s\n", path, strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		return errInvalidConfig
+	}
+
+	profiles := []string{profile}
+	if profile == "" {
+		names, err := configProfiles(path)
+		if err != nil {
+			return err
+		}
+		profiles = append(profiles, names...)
+	}
+
+	failed := false
+	for _, p := range profiles {
+		name := path
+		if p != "" {
+			name = fmt.Sprintf("%s (profile %q)", path, p)
+		}
+		cfg, err := resolveConfig(path, p, os.Getenv, nil)
+		if err == nil {
+			err = cfg.Validate()
+		}
+		if err != nil {
+			failed = true
+			fmt.Printf("%s:\n  %s\n", name, strings.ReplaceAll(err.Error(), "\n", "\n  "))
+			continue
+		}
+		fmt.Printf("%s: ok\n", name)
+	}
+	if failed {
+		return errInvalidConfig
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestRunCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `
+run = "./app"
+
+[profile.broken]
+health_policy = "sometimes"
+`)
+	if err := runCheck(path, ""); !errors.Is(err, errInvalidConfig) {
+		t.Errorf("Expected the broken profile to fail the check, got %v", err)
+	}
+	if err := runCheck(path, "broken"); !errors.Is(err, errInvalidConfig) {
+		t.Errorf("Expected the broken profile to fail the check, got %v", err)
+	}
+
+	path = writeConfig(t, dir, `run = "./app"
+helth_url = "http://localhost:8080"
+`)
+	if err := runCheck(path, ""); !errors.Is(err, errInvalidConfig) {
+		t.Errorf("Expected unknown keys to fail the check, got %v", err)
+	}
+
+	path = writeConfig(t, dir, `run = "./app"`)
+	if err := runCheck(path, ""); err != nil {
+		t.Errorf("Expected a valid config to pass, got %v", err)
+	}
+}
+
+func TestSetupCheck_Args(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, `run = "./app"`)
+	broken := writeConfig(t, t.TempDir(), `run = "./app"
+health_policy = "sometimes"
+`)
+	chdir(t, dir)
+
+	check := func(args ...string) error {
+		fs := flag.NewFlagSet("check", flag.ContinueOnError)
+		run := setupCheck(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return run(fs.Args())
+	}
+	if err := check(); err != nil {
+		t.Errorf("Expected the config found in the directory to pass, got %v", err)
+	}
+	if err := check(broken); !errors.Is(err, errInvalidConfig) {
+		t.Errorf("Expected the file given as an argument to be checked, got %v", err)
+	}
+	if err := check(broken, "extra"); err == nil || !strings.Contains(err.Error(), `unexpected argument "extra"`) {
+		t.Errorf("Expected extra arguments to be rejected, got %v", err)
+	}
+	t.Setenv("LIVERELOAD_CONFIG", broken)
+	if err := check(); !errors.Is(err, errInvalidConfig) {
+		t.Errorf("Expected LIVERELOAD_CONFIG to be checked, got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Set by goreleaser at build time.
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// command is a livereload subcommand. setup defines the command's flags on
+// fs and returns the function that runs it with the remaining arguments
+// once the flags are parsed. Help and completion call setup without
+// running the command, to learn its flags.
+type command struct {
+	name    string
+	args    string // synopsis of the arguments, after the name
+	summary string
+	setup   func(fs *flag.FlagSet) func(args []string) error
+}
+
+// commands lists the subcommands in the order help shows them. The first
+// one runs when no subcommand is named.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"run", "[flags]", "Build and run the app, reloading the browser on changes (the default)", setupRun},
+		{"serve", "[flags] [dir]", "Serve the files in dir, reloading the browser on changes", setupServe},
+		{"init", "[flags]", "Detect the project and write a livereload.toml for it", setupInit},
+		{"check", "[flags] [file]", "Validate the config file and its profiles without running anything", setupCheck},
+		{"reload", "[flags]", "Ask a running session to reload the browsers, or restart or rebuild the app", setupReload},
+		{"status", "[flags]", "Print the state of a running session", setupStatus},
+		{"schema", "", "Print the JSON Schema of the config file", setupSchema},
+		{"version", "", "Print the version", setupVersion},
+		{"completion", "bash|zsh|fish", "Print a shell completion script", setupCompletion},
+		{"help", "[command]", "Show help for livereload or one of its commands", setupHelp},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// runCommand runs the subcommand named by the first argument, or the
+// default one with all the arguments if the first is a flag or missing.
+func runCommand(args []string) error {
+	cmd := commands[0]
+	if len(args) > 0 {
+		switch arg := args[0]; {
+		case arg == "-h" || arg == "-help" || arg == "--help":
+			printHelp(os.Stdout)
+			return nil
+		case !strings.HasPrefix(arg, "-"):
+			if cmd = findCommand(arg); cmd == nil {
+				return fmt.Errorf("unknown command %q; run \"livereload help\" for a list", arg)
+			}
+			args = args[1:]
+		}
+	}
+	fs := cmd.flagSet()
+	run := cmd.setup(fs)
+	fs.Parse(args)
+	return run(fs.Args())
+}
+
+// flagSet returns an empty flag set for c that prints c's help on -h.
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("livereload "+c.name, flag.ExitOnError)
+	fs.Usage = func() { c.printUsage(fs.Output()) }
+	return fs
+}
+
+// printUsage prints the synopsis, summary and flags of c.
+func (c *command) printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: livereload %s %s\n\n%s.\n", c.name, c.args, c.summary)
+	fs := c.flagSet()
+	c.setup(fs)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintf(w, "\nFlags:\n")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintf(w, "Usage: livereload [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"livereload help <command>\" for the flags of a command.\n")
+}
+
+func setupHelp(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) == 0 {
+			printHelp(os.Stdout)
+			return nil
+		}
+		c := findCommand(args[0])
+		if c == nil {
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+		c.printUsage(os.Stdout)
+		return nil
+	}
+}
+
+func setupVersion(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		fmt.Println(versionString())
+		return nil
+	}
+}
+
+// versionString describes the build. Release builds get their details
+// from goreleaser; others, e.g. from go install, from the build info.
+func versionString() string {
+	v, c, d := version, commit, date
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" {
+		if info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && c == "":
+				c = s.Value
+			case s.Key == "vcs.time" && d == "":
+				d = s.Value
+			}
+		}
+	}
+	s := "livereload " + v
+	var details []string
+	if c != "" {
+		details = append(details, "commit "+c)
+	}
+	if d != "" {
+		details = append(details, "built "+d)
+	}
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s
+}
+
+func setupCompletion(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: livereload completion bash|zsh|fish")
+		}
+		switch args[0] {
+		case "bash":
+			writeBashCompletion(os.Stdout)
+		case "zsh":
+			writeZshCompletion(os.Stdout)
+		case "fish":
+			writeFishCompletion(os.Stdout)
+		default:
+			return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", args[0])
+		}
+		return nil
+	}
+}
+
+// completionFlag is a flag as shell completion needs it.
+type completionFlag struct {
+	name, usage string
+	isBool      bool
+}
+
+// commandFlags returns the flags of c, sorted by name.
+func commandFlags(c *command) []completionFlag {
+	fs := c.flagSet()
+	c.setup(fs)
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{f.Name, usage, ok && b.IsBoolFlag()})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+// positionalWords returns the words completed after the name of c.
+func positionalWords(c *command) []string {
+	switch c.name {
+	case "help":
+		var names []string
+		for _, c := range commands {
+			names = append(names, c.name)
+		}
+		return names
+	case "completion":
+		return []string{"bash", "zsh", "fish"}
+	}
+	return nil
+}
+
+func commandNames() string {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, " ")
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, `# bash completion for livereload. Load it with:
+#   source <(livereload completion bash)
+_livereload() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" cmd=%s
+    if [[ $COMP_CWORD -gt 1 && ${COMP_WORDS[1]} != -* ]]; then
+        cmd="${COMP_WORDS[1]}"
+    fi
+    if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+    local words
+    case "$cmd" in
+`, commands[0].name, commandNames())
+	for _, c := range commands {
+		var words []string
+		for _, f := range commandFlags(c) {
+			words = append(words, "--"+f.name)
+		}
+		fmt.Fprintf(w, "        %s) words=%q", c.name, strings.Join(words, " "))
+		if pos := positionalWords(c); pos != nil {
+			fmt.Fprintf(w, "; [[ $cur != -* ]] && words=%q", strings.Join(pos, " "))
+		}
+		fmt.Fprintf(w, " ;;\n")
+	}
+	fmt.Fprintf(w, `    esac
+    if [[ $cur == -* ]] || [[ -n $words && $words != --* ]]; then
+        COMPREPLY=($(compgen -W "$words" -- "$cur"))
+    fi
+}
+complete -o default -F _livereload livereload
+`)
+}
+
+// zshQuote escapes s for a single-quoted _arguments spec.
+func zshQuote(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintf(w, `#compdef livereload
+# zsh completion for livereload. Load it with:
+#   source <(livereload completion zsh)
+_livereload() {
+    local -a commands
+    commands=(
+`)
+	for _, c := range commands {
+		fmt.Fprintf(w, "        '%s:%s'\n", c.name, zshQuote(c.summary))
+	}
+	fmt.Fprintf(w, `    )
+    local cmd=%s
+    if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then
+        _describe command commands
+        return
+    fi
+    if [[ $words[2] != -* ]]; then
+        cmd=$words[2]
+        shift words
+        (( CURRENT-- ))
+    fi
+    case $cmd in
+`, commands[0].name)
+	for _, c := range commands {
+		fmt.Fprintf(w, "        %s)\n            _arguments", c.name)
+		for _, f := range commandFlags(c) {
+			if f.isBool {
+				fmt.Fprintf(w, " \\\n                '--%s[%s]'", f.name, zshQuote(f.usage))
+			} else {
+				fmt.Fprintf(w, " \\\n                '--%s=[%s]:value:_files'", f.name, zshQuote(f.usage))
+			}
+		}
+		if pos := positionalWords(c); pos != nil {
+			fmt.Fprintf(w, " \\\n                '1:argument:(%s)'", strings.Join(pos, " "))
+		}
+		fmt.Fprintf(w, "\n            ;;\n")
+	}
+	fmt.Fprintf(w, `    esac
+}
+compdef _livereload livereload
+`)
+}
+
+// fishQuote returns s as a single-quoted fish string.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for livereload. Load it with:\n#   livereload completion fish | source\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "complete -c livereload -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+	}
+	for i, c := range commands {
+		cond := "__fish_seen_subcommand_from " + c.name
+		if i == 0 {
+			// The default command's flags also apply without a subcommand.
+			cond = fmt.Sprintf("not __fish_seen_subcommand_from %s; or __fish_seen_subcommand_from %s", commandNames(), c.name)
+		}
+		for _, f := range commandFlags(c) {
+			fmt.Fprintf(w, "complete -c livereload -n %s -l %s -d %s", fishQuote(cond), f.name, fishQuote(f.usage))
+			if !f.isBool {
+				fmt.Fprintf(w, " -r")
+			}
+			fmt.Fprintf(w, "\n")
+		}
+		if pos := positionalWords(c); pos != nil {
+			fmt.Fprintf(w, "complete -c livereload -n %s -f -a %s\n", fishQuote(cond), fishQuote(strings.Join(pos, " ")))
+		}
+	}
+}
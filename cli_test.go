@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestVersionString(t *testing.T) {
+	defer func(v, c, d string) { version, commit, date = v, c, d }(version, commit, date)
+	version, commit, date = "1.2.3", "abc123", "2026-01-02T03:04:05Z"
+
+	if got, want := versionString(), "livereload 1.2.3 (commit abc123, built 2026-01-02T03:04:05Z)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestRunCommand_Unknown(t *testing.T) {
+	if err := runCommand([]string{"bogus"}); err == nil || !strings.Contains(err.Error(), `unknown command "bogus"`) {
+		t.Errorf("Expected unknown command error, got %v", err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for shell, write := range map[string]func(*bytes.Buffer){
+		"bash": func(b *bytes.Buffer) { writeBashCompletion(b) },
+		"zsh":  func(b *bytes.Buffer) { writeZshCompletion(b) },
+		"fish": func(b *bytes.Buffer) { writeFishCompletion(b) },
+	} {
+		var b bytes.Buffer
+		write(&b)
+		for _, c := range commands {
+			if !strings.Contains(b.String(), c.name) {
+				t.Errorf("%s: command %q missing", shell, c.name)
+			}
+		}
+		for _, want := range []string{"health-url", "force", "profile"} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s: flag %q missing", shell, want)
+			}
+		}
+
+		if path, err := exec.LookPath(shell); err == nil {
+			cmd := exec.Command(path, "-n")
+			cmd.Stdin = &b
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%s: syntax error in completion script: %v\n%s", shell, err, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,634 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/spwg/livereload/internal/livereload"
+	"go.yaml.in/yaml/v3"
+)
+
+//go:generate sh -c "go run . schema > livereload.schema.json"
+
+// configFileNames are the names searched for when no --config is given, in
+// order of preference. A pyproject.toml is only used if it has a
+// [tool.livereload] section.
+var configFileNames = []string{"livereload.toml", "livereload.yaml", "livereload.yml", "livereload.json", "pyproject.toml"}
+
+// Config is the contents of the config file.
+type Config struct {
+	Build     Command  `toml:"build" yaml:"build" json:"build"`
+	Run       Command  `toml:"run" yaml:"run" json:"run"`
+	Watch     []string `toml:"watch" yaml:"watch" json:"watch"`
+	Ignore    []string `toml:"ignore" yaml:"ignore" json:"ignore"`
+	Delay     int      `toml:"delay" yaml:"delay" json:"delay"`
+	HealthURL string   `toml:"health_url" yaml:"health_url" json:"health_url"`
+	Port      int      `toml:"port" yaml:"port" json:"port"`
+	Host      string   `toml:"host" yaml:"host" json:"host"`
+	Debounce  int      `toml:"debounce" yaml:"debounce" json:"debounce"`
+
+	HealthStatus int    `toml:"health_status" yaml:"health_status" json:"health_status"`
+	HealthBody   string `toml:"health_body" yaml:"health_body" json:"health_body"`
+	HealthTCP    string `toml:"health_tcp" yaml:"health_tcp" json:"health_tcp"`
+	HealthCmd    string `toml:"health_cmd" yaml:"health_cmd" json:"health_cmd"`
+	HealthLog    string `toml:"health_log" yaml:"health_log" json:"health_log"`
+
+	HealthTimeout  int    `toml:"health_timeout" yaml:"health_timeout" json:"health_timeout"`
+	HealthInterval int    `toml:"health_interval" yaml:"health_interval" json:"health_interval"`
+	HealthPolicy   string `toml:"health_policy" yaml:"health_policy" json:"health_policy"`
+
+	Dir     string            `toml:"dir" yaml:"dir" json:"dir"`
+	Env     map[string]string `toml:"env" yaml:"env" json:"env"`
+	EnvFile []string          `toml:"env_file" yaml:"env_file" json:"env_file"`
+
+	OutputPrefix bool   `toml:"output_prefix" yaml:"output_prefix" json:"output_prefix"`
+	Color        string `toml:"color" yaml:"color" json:"color"`
+	LogFile      string `toml:"log_file" yaml:"log_file" json:"log_file"`
+	LogMaxSize   int    `toml:"log_max_size" yaml:"log_max_size" json:"log_max_size"`
+	LogMaxFiles  int    `toml:"log_max_files" yaml:"log_max_files" json:"log_max_files"`
+
+	TLSCert       string `toml:"tls_cert" yaml:"tls_cert" json:"tls_cert"`
+	TLSKey        string `toml:"tls_key" yaml:"tls_key" json:"tls_key"`
+	TLSSelfSigned bool   `toml:"tls_self_signed" yaml:"tls_self_signed" json:"tls_self_signed"`
+
+	AllowedOrigins []string `toml:"allowed_origins" yaml:"allowed_origins" json:"allowed_origins"`
+
+	Routes []Route `toml:"routes" yaml:"routes" json:"routes"`
+
+	Serve    string `toml:"serve" yaml:"serve" json:"serve"`
+	ServeSPA bool   `toml:"serve_spa" yaml:"serve_spa" json:"serve_spa"`
+}
+
+// Route says which pages use the files it matches, so that changing them
+// only reloads those pages.
+type Route struct {
+	Files []string `toml:"files" yaml:"files" json:"files"`
+	Pages []string `toml:"pages" yaml:"pages" json:"pages"`
+}
+
+// Command is a build or run command. In the config file it is either a
+// string, run through sh -c, or an array of strings executed directly
+// without a shell.
+type Command struct {
+	Shell string
+	Args  []string
+}
+
+func (c *Command) UnmarshalTOML(node *unstable.Node) error {
+	switch node.Kind {
+	case unstable.String:
+		*c = Command{Shell: string(node.Data)}
+	case unstable.Array:
+		var args []string
+		it := node.Children()
+		for it.Next() {
+			n := it.Node()
+			if n.Kind != unstable.String {
+				return fmt.Errorf("command array must contain only strings, found %s", n.Kind)
+			}
+			args = append(args, string(n.Data))
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("command array must not be empty")
+		}
+		*c = Command{Args: args}
+	default:
+		return fmt.Errorf("command must be a string or an array of strings, found %s", node.Kind)
+	}
+	return nil
+}
+
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	// A TypeError lets the decoder go on and report other problems too.
+	fail := func(line int, msg string) error {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %s", line, msg)}}
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.ShortTag() != "!!str" {
+			return fail(node.Line, "command must be a string or an array of strings, found "+node.ShortTag())
+		}
+		*c = Command{Shell: node.Value}
+	case yaml.SequenceNode:
+		var args []string
+		for _, n := range node.Content {
+			if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!str" {
+				return fail(n.Line, "command array must contain only strings")
+			}
+			args = append(args, n.Value)
+		}
+		if len(args) == 0 {
+			return fail(node.Line, "command array must not be empty")
+		}
+		*c = Command{Args: args}
+	default:
+		return fail(node.Line, "command must be a string or an array of strings")
+	}
+	return nil
+}
+
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case string:
+		*c = Command{Shell: v}
+	case []any:
+		var args []string
+		for _, a := range v {
+			s, ok := a.(string)
+			if !ok {
+				return fmt.Errorf("command array must contain only strings, found %s", data)
+			}
+			args = append(args, s)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("command array must not be empty")
+		}
+		*c = Command{Args: args}
+	default:
+		return fmt.Errorf("command must be a string or an array of strings, found %s", data)
+	}
+	return nil
+}
+
+func (c Command) IsZero() bool {
+	return c.Shell == "" && len(c.Args) == 0
+}
+
+func (c Command) String() string {
+	if len(c.Args) == 0 {
+		return c.Shell
+	}
+	quoted := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		if a == "" || strings.ContainsAny(a, " \t\"'") {
+			a = strconv.Quote(a)
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
+
+// findConfig looks for a config file named in configFileNames in dir and
+// each of its parents and returns the path of the first one found, or "" if
+// there is none.
+func findConfig(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		for _, name := range configFileNames {
+			path := filepath.Join(d, name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if name == "pyproject.toml" && !hasToolSection(path) {
+				continue
+			}
+			return relPath(path), nil
+		}
+		if d == filepath.Dir(d) {
+			return "", nil
+		}
+	}
+}
+
+// relPath returns path relative to the current directory if it can be.
+func relPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// loadConfig reads the config file at path and lays it over cfg. If
+// profile is not empty, the [profile.<profile>] section is laid over the
+// top-level settings: its keys replace the top-level ones, except for
+// tables like env whose keys are merged. Relative paths are resolved
+// against the file's directory, and commands run there unless dir says
+// otherwise.
+func loadConfig(path, profile string, cfg Config) (Config, error) {
+	raw, err := readConfig(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	profiles, _ := raw["profile"].(map[string]any)
+	delete(raw, "profile")
+	delete(raw, "$schema")
+	if profile != "" {
+		overlay, ok := profiles[profile].(map[string]any)
+		if !ok {
+			return cfg, fmt.Errorf("%s: no profile %q (available: %s)", path, profile, profileNames(profiles))
+		}
+		for k, v := range overlay {
+			base, isTable := raw[k].(map[string]any)
+			table, overlayIsTable := v.(map[string]any)
+			if isTable && overlayIsTable {
+				for tk, tv := range table {
+					base[tk] = tv
+				}
+				continue
+			}
+			raw[k] = v
+		}
+	}
+
+	// Every format decodes to the same generic document, so it is
+	// converted to a Config the same way whatever the file's format.
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := cfg.interpolate(os.LookupEnv); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	cfg.resolvePaths(filepath.Dir(path))
+	return cfg, nil
+}
+
+// configKeys returns the keys accepted at the top level of a config file.
+func configKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("toml"); tag != "" && tag != "-" {
+			keys = append(keys, strings.Split(tag, ",")[0])
+		}
+	}
+	return append(keys, "profile")
+}
+
+// suggestKey returns the known key closest to an unknown one, or "" if
+// none is close enough to be a likely typo.
+func suggestKey(unknown string) string {
+	best, bestDist := "", len(unknown)/2+1
+	for _, key := range configKeys() {
+		if d := editDistance(strings.ToLower(unknown), key); d < bestDist {
+			best, bestDist = key, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// configProfiles returns the names of the profiles in the config file at
+// path, sorted.
+func configProfiles(path string) ([]string, error) {
+	raw, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles, _ := raw["profile"].(map[string]any)
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func profileNames(profiles map[string]any) string {
+	if len(profiles) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// resolvePaths makes the relative paths in cfg relative to base instead of
+// the current directory.
+func (cfg *Config) resolvePaths(base string) {
+	if base == "." {
+		return
+	}
+	resolve := func(p string) string {
+		p = strings.TrimSpace(p)
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(base, p)
+	}
+	for i, w := range cfg.Watch {
+		cfg.Watch[i] = resolve(w)
+	}
+	for i, f := range cfg.EnvFile {
+		cfg.EnvFile[i] = resolve(f)
+	}
+	if cfg.LogFile != "" {
+		cfg.LogFile = resolve(cfg.LogFile)
+	}
+	if cfg.TLSCert != "" {
+		cfg.TLSCert = resolve(cfg.TLSCert)
+	}
+	if cfg.Serve != "" {
+		cfg.Serve = resolve(cfg.Serve)
+	}
+	for _, r := range cfg.Routes {
+		for i, f := range r.Files {
+			// Patterns are slash-separated, like the changed paths
+			// they are matched against.
+			r.Files[i] = filepath.ToSlash(resolve(f))
+		}
+	}
+	if cfg.TLSKey != "" {
+		cfg.TLSKey = resolve(cfg.TLSKey)
+	}
+	if cfg.Dir != "" {
+		cfg.Dir = resolve(cfg.Dir)
+	} else {
+		cfg.Dir = base
+	}
+}
+
+// defaultConfig returns the settings used when neither the config file,
+// the environment nor the flags say otherwise.
+func defaultConfig() Config {
+	return Config{
+		Watch:          []string{"."},
+		Ignore:         []string{".git", "node_modules"},
+		Delay:          100,
+		Port:           35729,
+		Host:           "localhost",
+		Debounce:       100,
+		HealthTimeout:  5000,
+		HealthInterval: 50,
+		HealthPolicy:   string(livereload.HealthReload),
+		OutputPrefix:   true,
+		Color:          "auto",
+		LogMaxSize:     10,
+		LogMaxFiles:    3,
+	}
+}
+
+// settingUsage documents the config keys that can also be set with a
+// LIVERELOAD_<KEY> environment variable and a --<key> flag, where the flag
+// name is the key with dashes for underscores. Lists are comma-separated.
+var settingUsage = []struct{ key, usage string }{
+	{"build", "Shell `command` to build the project"},
+	{"run", "Shell `command` to run the executable"},
+	{"watch", "Comma-separated `paths` to watch (default .)"},
+	{"ignore", "Comma-separated directory and file `names` to ignore (default .git,node_modules)"},
+	{"port", "`port` for the livereload server (default 35729)"},
+	{"host", "`host` for the livereload server to bind to (default localhost)"},
+	{"debounce", "`ms` to wait for more file changes before restarting (default 100)"},
+	{"delay", "`ms` to wait after restart before reloading if no health check is configured (default 100)"},
+	{"health_url", "`URL` to poll for health check before reloading"},
+	{"health_status", "HTTP `status` health_url must return (default any 2xx or 3xx)"},
+	{"health_body", "`regexp` the health_url response body must match"},
+	{"health_tcp", "`host:port` that must accept TCP connections before reloading"},
+	{"health_cmd", "Shell `command` that must exit 0 before reloading"},
+	{"health_log", "`regexp` the app's output must match before reloading"},
+	{"health_timeout", "`ms` to wait for the health check to pass (default 5000)"},
+	{"health_interval", "`ms` between health check attempts (default 50)"},
+	{"health_policy", "`policy` on health check timeout: reload, skip, restart or overlay (default reload)"},
+	{"dir", "Working `directory` for the build and run commands"},
+	{"env_file", "Comma-separated dotenv `files` to load into the build and run environment"},
+	{"output_prefix", "Prefix each line of child process output with its step (default true)"},
+	{"color", "`when` to color child process output: auto, always or never (default auto)"},
+	{"log_file", "Also write child process output to `file`, rotated by size"},
+	{"log_max_size", "`MB` after which log_file is rotated (default 10)"},
+	{"log_max_files", "`number` of rotated log files to keep (default 3)"},
+	{"tls_cert", "Certificate `file` to serve the livereload server over HTTPS and WSS"},
+	{"tls_key", "Private key `file` for tls_cert"},
+	{"tls_self_signed", "Serve over HTTPS with a generated self-signed certificate"},
+	{"serve", "Serve the files in `directory` from the livereload server, with the script added to HTML pages; makes run optional"},
+	{"serve_spa", "Serve index.html for paths that match no file, for single-page apps"},
+	{"allowed_origins", "Comma-separated `origins` or hosts of pages that may connect, or * for any (default localhost and the origin of health_url)"},
+}
+
+// flagName returns the command-line flag for a config key.
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// envName returns the environment variable for a config key.
+func envName(key string) string {
+	return "LIVERELOAD_" + strings.ToUpper(key)
+}
+
+// resolveConfig builds the effective configuration from, in increasing
+// order of precedence: the defaults, the config file at path (if not
+// empty) with profile applied, LIVERELOAD_* environment variables, and
+// flags, which maps config keys to the values of the flags that were set
+// explicitly.
+func resolveConfig(path, profile string, getenv func(string) string, flags map[string]string) (Config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		var err error
+		if cfg, err = loadConfig(path, profile, cfg); err != nil {
+			return cfg, err
+		}
+	}
+	for _, s := range settingUsage {
+		if v := getenv(envName(s.key)); v != "" {
+			if err := cfg.set(s.key, v); err != nil {
+				return cfg, fmt.Errorf("%s: %v", envName(s.key), err)
+			}
+		}
+	}
+	for _, s := range settingUsage {
+		if v, ok := flags[s.key]; ok {
+			if err := cfg.set(s.key, v); err != nil {
+				return cfg, fmt.Errorf("--%s: %v", flagName(s.key), err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// isBoolKey reports whether the setting for key is a boolean, which its
+// flag then is too.
+func isBoolKey(key string) bool {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("toml"), ",")[0] == key {
+			return t.Field(i).Type.Kind() == reflect.Bool
+		}
+	}
+	return false
+}
+
+// set parses value into the field for key. Commands given this way always
+// use the shell form.
+func (cfg *Config) set(key, value string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("toml"), ",")[0] != key {
+			continue
+		}
+		switch f := v.Field(i).Addr().Interface().(type) {
+		case *string:
+			*f = value
+		case *int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%q is not an integer", value)
+			}
+			*f = n
+		case *bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%q is not a boolean", value)
+			}
+			*f = b
+		case *[]string:
+			*f = strings.Split(value, ",")
+			for j := range *f {
+				(*f)[j] = strings.TrimSpace((*f)[j])
+			}
+		case *Command:
+			*f = Command{Shell: value}
+		default:
+			return fmt.Errorf("cannot be set from a flag or environment variable")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown setting %q", key)
+}
+
+// Validate checks the settings for values that would otherwise only fail,
+// or misbehave, once livereload is running. It reports every problem found.
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(!cfg.Run.IsZero() || cfg.Serve != "", "run: a run command is required (--run flag or 'run' in the config file), unless serving files with serve")
+	check(cfg.Delay >= 0, "delay: must not be negative, got %d", cfg.Delay)
+	check(cfg.Debounce >= 0, "debounce: must not be negative, got %d", cfg.Debounce)
+	check(cfg.Port > 0 && cfg.Port < 65536, "port: %d is not a valid port", cfg.Port)
+	check(cfg.Host != "", "host: must not be empty")
+	check(cfg.HealthTimeout > 0, "health_timeout: must be positive, got %d", cfg.HealthTimeout)
+	check(cfg.HealthInterval > 0, "health_interval: must be positive, got %d", cfg.HealthInterval)
+	check(cfg.LogMaxSize > 0, "log_max_size: must be positive, got %d", cfg.LogMaxSize)
+	check(cfg.LogMaxFiles >= 0, "log_max_files: must not be negative, got %d", cfg.LogMaxFiles)
+
+	if cfg.HealthURL != "" {
+		u, err := url.Parse(cfg.HealthURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"health_url: %q is not an http or https URL", cfg.HealthURL)
+	}
+	check(cfg.HealthStatus == 0 || (cfg.HealthStatus >= 100 && cfg.HealthStatus <= 599),
+		"health_status: %d is not an HTTP status code", cfg.HealthStatus)
+	check(cfg.HealthURL != "" || (cfg.HealthStatus == 0 && cfg.HealthBody == ""),
+		"health_status and health_body require health_url")
+	if _, err := regexp.Compile(cfg.HealthBody); err != nil {
+		errs = append(errs, fmt.Errorf("health_body: %v", err))
+	}
+	if _, err := regexp.Compile(cfg.HealthLog); err != nil {
+		errs = append(errs, fmt.Errorf("health_log: %v", err))
+	}
+	if cfg.HealthTCP != "" {
+		_, port, err := net.SplitHostPort(cfg.HealthTCP)
+		check(err == nil && port != "", "health_tcp: %q is not a host:port address", cfg.HealthTCP)
+	}
+	if _, err := livereload.ParseHealthPolicy(cfg.HealthPolicy); err != nil {
+		errs = append(errs, fmt.Errorf("health_policy: %v", err))
+	}
+	switch cfg.Color {
+	case "", "auto", "always", "never":
+	default:
+		errs = append(errs, fmt.Errorf("color: must be auto, always or never, got %q", cfg.Color))
+	}
+
+	check((cfg.TLSCert == "") == (cfg.TLSKey == ""), "tls_cert and tls_key must be set together")
+	check(!cfg.TLSSelfSigned || cfg.TLSCert == "", "tls_self_signed: cannot be combined with tls_cert")
+	if cfg.TLSCert != "" && cfg.TLSKey != "" {
+		if _, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey); err != nil {
+			errs = append(errs, fmt.Errorf("tls_cert: %v", err))
+		}
+	}
+
+	for _, o := range cfg.AllowedOrigins {
+		if o = strings.TrimSpace(o); o == "*" || !strings.Contains(o, "://") {
+			check(o != "" && !strings.ContainsAny(o, "/?#"), "allowed_origins: %q is not an origin or host", o)
+			continue
+		}
+		u, err := url.Parse(o)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && strings.TrimSuffix(u.Path, "/") == "",
+			"allowed_origins: %q is not an origin such as http://localhost:8080", o)
+	}
+
+	for i, r := range cfg.Routes {
+		check(len(r.Files) > 0 && len(r.Pages) > 0, "routes[%d]: both files and pages are required", i)
+		for _, p := range append(slices.Clone(r.Files), r.Pages...) {
+			_, err := livereload.MatchPath(p, "")
+			check(err == nil, "routes[%d]: %q is not a valid pattern", i, p)
+		}
+	}
+
+	for _, w := range cfg.Watch {
+		_, err := os.Stat(strings.TrimSpace(w))
+		check(err == nil, "watch: %v", err)
+	}
+	if cfg.Serve != "" {
+		info, err := os.Stat(cfg.Serve)
+		check(err == nil && info.IsDir(), "serve: %q is not a directory", cfg.Serve)
+	}
+	check(!cfg.ServeSPA || cfg.Serve != "", "serve_spa requires serve")
+	if cfg.Dir != "" {
+		info, err := os.Stat(cfg.Dir)
+		check(err == nil && info.IsDir(), "dir: %q is not a directory", cfg.Dir)
+	}
+	for _, f := range cfg.EnvFile {
+		if _, err := os.Stat(f); err != nil {
+			errs = append(errs, fmt.Errorf("env_file: %v", err))
+		} else if _, err := livereload.ParseEnvFile(f); err != nil {
+			errs = append(errs, fmt.Errorf("env_file: %v", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes content to dir/livereload.toml and returns its path.
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "livereload.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// chdir changes into dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, root, `run = "./app"`)
+	chdir(t, sub)
+
+	path, err := findConfig(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("..", "..", "livereload.toml"); path != want {
+		t.Errorf("Expected %q, got %q", want, path)
+	}
+
+	chdir(t, t.TempDir())
+	if path, err := findConfig("."); err != nil || path != "" {
+		t.Errorf("Expected no config, got %q, %v", path, err)
+	}
+}
+
+func TestLoadConfig_Profiles(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `
+run = "./app"
+watch = ["src"]
+health_url = "http://localhost:8080"
+
+[env]
+PORT = "8080"
+MODE = "dev"
+
+[profile.e2e]
+run = ["./app", "--e2e"]
+health_policy = "restart"
+
+[profile.e2e.env]
+MODE = "test"
+`)
+
+	cfg, err := loadConfig(path, "", defaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Run.Shell != "./app" || cfg.Env["MODE"] != "dev" {
+		t.Errorf("Unexpected base config: %+v", cfg)
+	}
+
+	cfg, err = loadConfig(path, "e2e", defaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Run.Args, []string{"./app", "--e2e"}) {
+		t.Errorf("Expected profile run command, got %v", cfg.Run)
+	}
+	if cfg.HealthPolicy != "restart" || cfg.HealthURL != "http://localhost:8080" {
+		t.Errorf("Expected profile overlaid on base, got %+v", cfg)
+	}
+	if want := map[string]string{"PORT": "8080", "MODE": "test"}; !reflect.DeepEqual(cfg.Env, want) {
+		t.Errorf("Expected merged env %v, got %v", want, cfg.Env)
+	}
+	if want := []string{filepath.Join(dir, "src")}; !reflect.DeepEqual(cfg.Watch, want) {
+		t.Errorf("Expected watch resolved against config dir %v, got %v", want, cfg.Watch)
+	}
+	if cfg.Dir != dir {
+		t.Errorf("Expected commands to run in %q, got %q", dir, cfg.Dir)
+	}
+
+	if _, err := loadConfig(path, "prod", defaultConfig()); err == nil || !strings.Contains(err.Error(), "available: e2e") {
+		t.Errorf("Expected unknown profile error, got %v", err)
+	}
+}
+
+func TestLoadConfig_UnknownKeys(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), `run = "./app"
+helth_url = "http://localhost:8080"
+
+[profile.dev]
+ignores = ["tmp"]
+`)
+	_, err := loadConfig(path, "", defaultConfig())
+	if err == nil {
+		t.Fatal("Expected unknown keys to be rejected")
+	}
+	for _, want := range []string{
+		`:2:1: unknown key "helth_url" (did you mean "health_url"?)`,
+		`:5:1: unknown key "profile.dev.ignores" (did you mean "ignore"?)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in error, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	dir := t.TempDir()
+	valid := func() Config {
+		cfg := defaultConfig()
+		cfg.Run = Command{Shell: "./app"}
+		cfg.Watch = []string{dir}
+		return cfg
+	}
+	if cfg := valid(); cfg.Validate() != nil {
+		t.Fatalf("Expected valid config, got %v", cfg.Validate())
+	}
+	serving := valid()
+	serving.Run, serving.Serve = Command{}, dir
+	if err := serving.Validate(); err != nil {
+		t.Errorf("Expected serve to make run optional, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"missing run", func(c *Config) { c.Run = Command{} }, "run: a run command is required"},
+		{"negative delay", func(c *Config) { c.Delay = -1 }, "delay: must not be negative"},
+		{"missing watch dir", func(c *Config) { c.Watch = []string{filepath.Join(dir, "nope")} }, "watch:"},
+		{"bad health url", func(c *Config) { c.HealthURL = "localhost:8080" }, "health_url:"},
+		{"body without url", func(c *Config) { c.HealthBody = "ok" }, "require health_url"},
+		{"bad regexp", func(c *Config) { c.HealthLog = "(" }, "health_log:"},
+		{"bad tcp addr", func(c *Config) { c.HealthTCP = "localhost" }, "health_tcp:"},
+		{"bad policy", func(c *Config) { c.HealthPolicy = "ignore" }, "health_policy:"},
+		{"bad color", func(c *Config) { c.Color = "yes" }, "color:"},
+		{"dir is a file", func(c *Config) { c.Dir = writeConfig(t, dir, "") }, "dir:"},
+		{"cert without key", func(c *Config) { c.TLSCert = "cert.pem" }, "tls_cert and tls_key"},
+		{"unreadable cert", func(c *Config) { c.TLSCert, c.TLSKey = "cert.pem", "key.pem" }, "tls_cert:"},
+		{"origin with path", func(c *Config) { c.AllowedOrigins = []string{"http://localhost:3000/app"} }, "allowed_origins:"},
+		{"origin not http", func(c *Config) { c.AllowedOrigins = []string{"ftp://localhost"} }, "allowed_origins:"},
+		{"route without pages", func(c *Config) { c.Routes = []Route{{Files: []string{"templates/**"}}} }, "routes[0]: both"},
+		{"bad route pattern", func(c *Config) { c.Routes = []Route{{Files: []string{"templates/["}, Pages: []string{"/"}}} }, "routes[0]:"},
+		{"serve a file", func(c *Config) { c.Serve = writeConfig(t, dir, "") }, "serve:"},
+		{"spa without serve", func(c *Config) { c.ServeSPA = true }, "serve_spa requires serve"},
+		{"cert and self-signed", func(c *Config) { c.TLSCert, c.TLSKey, c.TLSSelfSigned = "cert.pem", "key.pem", true }, "tls_self_signed:"},
+	}
+	for _, tt := range tests {
+		cfg := valid()
+		tt.modify(&cfg)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestResolveConfig_Precedence(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), `
+run = "./from-file"
+delay = 250
+port = 4000
+`)
+	env := map[string]string{
+		"LIVERELOAD_PORT": "5000",
+		"LIVERELOAD_HOST": "127.0.0.1",
+	}
+	flags := map[string]string{
+		"host":  "0.0.0.0",
+		"watch": "src, templates",
+	}
+	getenv := func(k string) string { return env[k] }
+	noenv := func(string) string { return "" }
+
+	tests := []struct {
+		name   string
+		path   string
+		getenv func(string) string
+		flags  map[string]string
+		want   func(Config) bool
+	}{
+		{"default", "", noenv, nil, func(c Config) bool {
+			return c.Delay == 100 && c.Port == 35729 && c.Host == "localhost" && c.Run.IsZero()
+		}},
+		{"file over default", path, noenv, nil, func(c Config) bool {
+			return c.Delay == 250 && c.Port == 4000 && c.Host == "localhost" && c.Run.Shell == "./from-file"
+		}},
+		{"env over file", path, getenv, nil, func(c Config) bool {
+			return c.Delay == 250 && c.Port == 5000 && c.Host == "127.0.0.1"
+		}},
+		{"flag over env", path, getenv, flags, func(c Config) bool {
+			return c.Delay == 250 && c.Port == 5000 && c.Host == "0.0.0.0" &&
+				reflect.DeepEqual(c.Watch, []string{"src", "templates"})
+		}},
+		{"env without file", "", getenv, nil, func(c Config) bool {
+			return c.Delay == 100 && c.Port == 5000
+		}},
+		{"flag without file", "", noenv, map[string]string{"delay": "0", "output_prefix": "false"}, func(c Config) bool {
+			return c.Delay == 0 && !c.OutputPrefix
+		}},
+	}
+	for _, tt := range tests {
+		cfg, err := resolveConfig(tt.path, "", tt.getenv, tt.flags)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !tt.want(cfg) {
+			t.Errorf("%s: unexpected config %+v", tt.name, cfg)
+		}
+	}
+
+	_, err := resolveConfig("", "", func(k string) string {
+		if k == "LIVERELOAD_DELAY" {
+			return "soon"
+		}
+		return ""
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "LIVERELOAD_DELAY") {
+		t.Errorf("Expected error naming LIVERELOAD_DELAY, got %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// configFile is the layout of a config file: the top-level settings plus
+// named profiles that overlay them.
+type configFile struct {
+	Config  `yaml:",inline"`
+	Profile map[string]Config `toml:"profile" yaml:"profile" json:"profile"`
+
+	// Schema lets JSON files name their schema for editors.
+	Schema string `toml:"-" yaml:"-" json:"$schema"`
+}
+
+// configFormat reads config files of one format. check reports unknown
+// keys and values of the wrong type with their position in the file, and
+// parse decodes a file that passed check into a generic document.
+type configFormat struct {
+	check func(path string, data []byte) error
+	parse func(data []byte) (map[string]any, error)
+}
+
+// configFormats maps file extensions to the formats read from them.
+var configFormats = map[string]configFormat{
+	".toml": {checkTOML, parseTOML},
+	".yaml": {checkYAML, parseYAML},
+	".yml":  {checkYAML, parseYAML},
+	".json": {checkJSON, parseJSON},
+}
+
+// readConfig reads and checks the config file at path, picking its format
+// by extension, and returns its settings, profiles included, as a generic
+// document.
+func readConfig(path string) (map[string]any, error) {
+	format, ok := configFormats[filepath.Ext(path)]
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported config file format (want .toml, .yaml, .yml or .json)", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := format.check(path, data); err != nil {
+		return nil, err
+	}
+	raw, err := format.parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if raw == nil {
+		raw = make(map[string]any)
+	}
+	return raw, nil
+}
+
+// unknownKeyError reports an unknown key, suggesting the known key it is
+// most likely a typo of.
+func unknownKeyError(pos, key string) error {
+	msg := fmt.Sprintf("%s: unknown key %q", pos, key)
+	if s := suggestKey(key[strings.LastIndex(key, ".")+1:]); s != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", s)
+	}
+	return errors.New(msg)
+}
+
+// toolSection is a TOML file, like pyproject.toml, that keeps the settings
+// in a [tool.livereload] section among those of other tools.
+type toolSection struct {
+	Tool struct {
+		Livereload configFile `toml:"livereload"`
+	} `toml:"tool"`
+}
+
+// hasToolSection reports whether the TOML file at path has a
+// [tool.livereload] section.
+func hasToolSection(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	_, ok := toolSectionOf(data)
+	return ok
+}
+
+// toolSectionOf returns the [tool.livereload] section of a TOML document.
+func toolSectionOf(data []byte) (map[string]any, bool) {
+	var raw struct {
+		Tool struct {
+			Livereload map[string]any `toml:"livereload"`
+		} `toml:"tool"`
+	}
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, false
+	}
+	return raw.Tool.Livereload, raw.Tool.Livereload != nil
+}
+
+func parseTOML(data []byte) (map[string]any, error) {
+	if section, ok := toolSectionOf(data); ok {
+		return section, nil
+	}
+	var raw map[string]any
+	err := toml.Unmarshal(data, &raw)
+	return raw, err
+}
+
+// checkTOML checks that data decodes into a configFile, or a toolSection,
+// without unknown keys. Errors carry the line and column of the problem.
+func checkTOML(path string, data []byte) error {
+	var err error
+	var prefix []string
+	dec := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().EnableUnmarshalerInterface()
+	if _, ok := toolSectionOf(data); ok {
+		// Keys of other tools are none of our business.
+		prefix = []string{"tool", "livereload"}
+		err = dec.Decode(&toolSection{})
+	} else {
+		err = dec.Decode(&configFile{})
+	}
+
+	var strict *toml.StrictMissingError
+	if errors.As(err, &strict) {
+		var errs []error
+		for _, e := range strict.Errors {
+			key := e.Key()
+			if !slices.Equal(key[:min(len(prefix), len(key))], prefix) || len(key) == len(prefix) {
+				continue
+			}
+			row, col := e.Position()
+			errs = append(errs, unknownKeyError(fmt.Sprintf("%s:%d:%d", path, row, col), strings.Join(key, ".")))
+		}
+		return errors.Join(errs...)
+	}
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, col := decodeErr.Position()
+		msg := strings.TrimPrefix(decodeErr.Error(), "toml: ")
+		// Name the key rather than the Go struct field.
+		msg = goFieldRe.ReplaceAllStringFunc(msg, func(m string) string {
+			field := goFieldRe.FindStringSubmatch(m)[1]
+			if f, ok := reflect.TypeOf(Config{}).FieldByName(field); ok {
+				return fmt.Sprintf("key %q", strings.Split(f.Tag.Get("toml"), ",")[0])
+			}
+			return m
+		})
+		return fmt.Errorf("%s:%d:%d: %s", path, row, col, msg)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+var goFieldRe = regexp.MustCompile(`struct field main\.[\w.]+\.(\w+)`)
+
+func parseYAML(data []byte) (map[string]any, error) {
+	var raw map[string]any
+	err := yaml.Unmarshal(data, &raw)
+	return raw, err
+}
+
+// checkYAML checks that data decodes into a configFile without unknown
+// keys. Errors carry the line of the problem.
+func checkYAML(path string, data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err := dec.Decode(&configFile{})
+	if err == nil || err == io.EOF {
+		return nil
+	}
+
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	var errs []error
+	for _, msg := range msgs {
+		pos := path
+		if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+			pos, msg = path+":"+m[1], m[2]
+		}
+		if m := yamlUnknownRe.FindStringSubmatch(msg); m != nil {
+			errs = append(errs, unknownKeyError(pos, m[1]))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %s", pos, msg))
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	yamlLineRe    = regexp.MustCompile(`^line (\d+): (.*)$`)
+	yamlUnknownRe = regexp.MustCompile(`^field (\S+) not found in type`)
+)
+
+func parseJSON(data []byte) (map[string]any, error) {
+	var raw map[string]any
+	err := json.Unmarshal(data, &raw)
+	return raw, err
+}
+
+// checkJSON checks that data decodes into a configFile without unknown
+// keys. The decoder stops at the first problem, so at most one is reported.
+func checkJSON(path string, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&configFile{})
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("%s: %s", jsonPos(path, data, syntaxErr.Offset), strings.TrimPrefix(err.Error(), "json: "))
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("%s: key %q: cannot use %s as %s", jsonPos(path, data, typeErr.Offset), typeErr.Field, typeErr.Value, typeErr.Type)
+	}
+	if m := jsonUnknownRe.FindStringSubmatch(err.Error()); m != nil {
+		key, _ := strconv.Unquote(m[1])
+		offset := int64(-1)
+		if loc := regexp.MustCompile(regexp.QuoteMeta(m[1]) + `\s*:`).FindIndex(data); loc != nil {
+			offset = int64(loc[0])
+		}
+		return unknownKeyError(jsonPos(path, data, offset), key)
+	}
+	return fmt.Errorf("%s: %s", path, strings.TrimPrefix(err.Error(), "json: "))
+}
+
+var jsonUnknownRe = regexp.MustCompile(`^json: unknown field ("(?:[^"\\]|\\.)*")$`)
+
+// jsonPos returns path with the line and column of offset in data, or just
+// path if offset is negative.
+func jsonPos(path string, data []byte, offset int64) string {
+	if offset < 0 || offset > int64(len(data)) {
+		return path
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("%s:%d:%d", path, line, col)
+}
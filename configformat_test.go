@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_Formats(t *testing.T) {
+	dir := t.TempDir()
+	want, err := loadConfig(writeConfig(t, dir, `
+run = ["./app", "--dev"]
+build = "make"
+port = 4000
+output_prefix = false
+
+[env]
+MODE = "dev"
+
+[profile.e2e]
+delay = 5
+`), "e2e", defaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{
+		"livereload.yaml": `
+run: ["./app", "--dev"]
+build: make
+port: 4000
+output_prefix: false
+env:
+  MODE: dev
+profile:
+  e2e:
+    delay: 5
+`,
+		"livereload.json": `{
+  "$schema": "./livereload.schema.json",
+  "run": ["./app", "--dev"],
+  "build": "make",
+  "port": 4000,
+  "output_prefix": false,
+  "env": {"MODE": "dev"},
+  "profile": {"e2e": {"delay": 5}}
+}`,
+		"pyproject.toml": `
+[project]
+name = "app"
+
+[tool.black]
+line-length = 100
+
+[tool.livereload]
+run = ["./app", "--dev"]
+build = "make"
+port = 4000
+output_prefix = false
+
+[tool.livereload.env]
+MODE = "dev"
+
+[tool.livereload.profile.e2e]
+delay = 5
+`,
+	} {
+		got, err := loadConfig(writeFile(t, filepath.Join(dir, name), content), "e2e", defaultConfig())
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected\n%+v\ngot\n%+v", name, want, got)
+		}
+	}
+}
+
+func TestLoadConfig_FormatErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, tc := range map[string]struct {
+		content string
+		want    []string
+	}{
+		"livereload.yaml": {"run: ./app\nbulid: make\nport: abc\nprofile:\n  dev:\n    prot: 1\n", []string{
+			`livereload.yaml:2: unknown key "bulid" (did you mean "build"?)`,
+			`livereload.yaml:3: cannot unmarshal`,
+			`livereload.yaml:6: unknown key "prot" (did you mean "port"?)`,
+		}},
+		"livereload.json": {"{\n  \"run\": \"./app\",\n  \"hots\": \"x\"\n}", []string{
+			`livereload.json:3:3: unknown key "hots" (did you mean "host"?)`,
+		}},
+		"other.json": {"{\n  \"run\": \"./app\",\n  \"port\": \"x\"\n}", []string{
+			`other.json:3:14: key "port": cannot use string as int`,
+		}},
+		"pyproject.toml": {"[tool.black]\nline-length = 1\n\n[tool.livereload]\nrun = \"./app\"\nwatchh = [\".\"]\n", []string{
+			`pyproject.toml:6:1: unknown key "tool.livereload.watchh" (did you mean "watch"?)`,
+		}},
+		"livereload.ini": {"run = ./app", []string{"unsupported config file format"}},
+	} {
+		_, err := loadConfig(writeFile(t, filepath.Join(dir, name), tc.content), "", defaultConfig())
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: expected %q in error, got:\n%v", name, want, err)
+			}
+		}
+		if name == "pyproject.toml" && strings.Contains(err.Error(), "line-length") {
+			t.Errorf("Expected other tools' keys to be ignored, got:\n%v", err)
+		}
+	}
+}
+
+func TestFindConfig_Formats(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "livereload.yaml"), "run: ./app\n")
+	// A pyproject.toml without a [tool.livereload] section isn't ours.
+	writeFile(t, filepath.Join(sub, "pyproject.toml"), "[project]\nname = \"app\"\n")
+	chdir(t, sub)
+
+	path, err := findConfig(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("..", "livereload.yaml"); path != want {
+		t.Errorf("Expected %q, got %q", want, path)
+	}
+
+	writeFile(t, filepath.Join(sub, "pyproject.toml"), "[tool.livereload]\nrun = \"./app\"\n")
+	if path, err := findConfig("."); err != nil || path != "pyproject.toml" {
+		t.Errorf("Expected pyproject.toml, got %q, %v", path, err)
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	data, err := os.ReadFile("livereload.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != string(want) {
+		t.Error("livereload.schema.json is out of date; run go generate")
+	}
+
+	// Every key the loader accepts is in the schema.
+	props := configSchema()["properties"].(map[string]any)
+	for _, key := range configKeys() {
+		if _, ok := props[key]; !ok {
+			t.Errorf("Key %q missing from schema", key)
+		}
+	}
+}
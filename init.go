@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// setupInit defines the flags of "livereload init", which looks at the
+// project in the current directory and writes a commented livereload.toml
+// for it.
+func setupInit(fs *flag.FlagSet) func(args []string) error {
+	force := fs.Bool("force", false, "Overwrite an existing livereload.toml")
+	print := fs.Bool("print", false, "Print the config instead of writing it")
+	return func(args []string) error {
+		return runInit(*force, *print)
+	}
+}
+
+func runInit(force, print bool) error {
+	p, err := detectProject(".")
+	if err != nil {
+		return err
+	}
+	out := p.render()
+	if print {
+		os.Stdout.Write(out)
+		return nil
+	}
+
+	const target = "livereload.toml"
+	if _, err := os.Stat(target); err == nil && !force {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", target)
+	}
+	if err := os.WriteFile(target, out, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Detected %s.\n", p.kind)
+	fmt.Printf("Wrote %s. Review it, then start livereload.\n", target)
+	for _, n := range p.notes {
+		fmt.Printf("Note: %s\n", n)
+	}
+	return nil
+}
+
+// proposal is the configuration init suggests for a project.
+type proposal struct {
+	kind      string // what was detected, e.g. "a Go module"
+	build     string
+	run       string
+	watch     []string
+	ignore    []string
+	healthURL string   // empty if no port was found
+	notes     []string // printed after writing the file
+}
+
+// buildDir is where init puts build output. It is ignored, since a change
+// to the build output must not trigger another build.
+const buildDir = "tmp"
+
+// detectProject inspects dir and proposes settings for the first kind of
+// project it recognizes: a Go module, a Node package, a Python project or
+// a Makefile.
+func detectProject(dir string) (*proposal, error) {
+	for _, detect := range []func(string) (*proposal, error){detectGo, detectNode, detectPython, detectMake} {
+		p, err := detect(dir)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			continue
+		}
+		p.watch = []string{"."}
+		p.ignore = append([]string{".git"}, p.ignore...)
+		if out := buildOutput(p.build); out != "" && !slices.Contains(p.ignore, out) {
+			p.ignore = append(p.ignore, out)
+		}
+		return p, nil
+	}
+	return nil, errors.New("no go.mod, package.json, pyproject.toml or Makefile found; write livereload.toml by hand")
+}
+
+// detectGo proposes building the module's main package into buildDir.
+// Besides a module root, dir may be a package inside a module.
+func detectGo(dir string) (*proposal, error) {
+	if gofiles, _ := filepath.Glob(filepath.Join(dir, "*.go")); !fileExists(filepath.Join(dir, "go.mod")) && len(gofiles) == 0 {
+		return nil, nil
+	}
+	mains, err := goMainPackages(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(mains) == 0 {
+		return nil, errors.New("no main package to run found")
+	}
+	pkg := mains[0]
+	name, pkgPath := filepath.Base(pkg), "./"+filepath.ToSlash(pkg)
+	if pkg == "." {
+		name, pkgPath = "app", "."
+	}
+	p := &proposal{
+		kind:  fmt.Sprintf("a Go module with main package %s", pkgPath),
+		build: fmt.Sprintf("go build -o %s/%s %s", buildDir, name, pkgPath),
+		run:   fmt.Sprintf("./%s/%s", buildDir, name),
+	}
+	if len(mains) > 1 {
+		p.notes = append(p.notes, fmt.Sprintf("found several main packages (%s); using %s", strings.Join(mains, ", "), pkgPath))
+	}
+	if port := findPort(filepath.Join(dir, pkg), ".go", goListenRe); port != "" {
+		p.healthURL = "http://localhost:" + port
+	}
+	return p, nil
+}
+
+var goListenRe = regexp.MustCompile(`(?:Listen\w*\(|Addr:)\s*"[\w.-]*:(\d{2,5})"`)
+
+// goMainPackages returns the directories under dir, relative to it, that
+// hold a main package. The root package comes first, then packages named
+// after the module, then the rest in order.
+func goMainPackages(dir string) ([]string, error) {
+	seen := make(map[string]bool)
+	var mains []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil || seen[rel] {
+			return err
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly)
+		if err == nil && f.Name.Name == "main" {
+			seen[rel] = true
+			mains = append(mains, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	module := ""
+	if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		if m := goModuleRe.FindSubmatch(data); m != nil {
+			module = string(m[1])
+		}
+	}
+	rank := func(pkg string) int {
+		switch {
+		case pkg == ".":
+			return 0
+		case filepath.Base(pkg) == path.Base(module):
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(mains, func(i, j int) bool { return rank(mains[i]) < rank(mains[j]) })
+	return mains, nil
+}
+
+var goModuleRe = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)`)
+
+// detectNode proposes the package's build script and its dev or start
+// script, run with the package manager whose lock file is present.
+func detectNode(dir string) (*proposal, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, nil
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("package.json: %v", err)
+	}
+
+	pm := "npm"
+	for _, lock := range []struct{ file, pm string }{{"pnpm-lock.yaml", "pnpm"}, {"yarn.lock", "yarn"}, {"bun.lockb", "bun"}} {
+		if fileExists(filepath.Join(dir, lock.file)) {
+			pm = lock.pm
+			break
+		}
+	}
+	p := &proposal{kind: fmt.Sprintf("a Node package (using %s)", pm), ignore: []string{"node_modules"}}
+	if _, ok := pkg.Scripts["build"]; ok {
+		p.build = pm + " run build"
+	}
+	var script string
+	for _, s := range []string{"dev", "start", "serve"} {
+		if _, ok := pkg.Scripts[s]; ok {
+			script = s
+			break
+		}
+	}
+	if script == "" {
+		return nil, errors.New("package.json has no dev, start or serve script to run")
+	}
+	p.run = pm + " run " + script
+
+	cmd := pkg.Scripts[script]
+	if m := nodePortRe.FindStringSubmatch(cmd); m != nil {
+		p.healthURL = "http://localhost:" + m[1]
+	} else if strings.Contains(cmd, "vite") {
+		p.healthURL = "http://localhost:5173"
+	} else if strings.Contains(cmd, "next") {
+		p.healthURL = "http://localhost:3000"
+	}
+	for _, out := range []string{"dist", "build", "out", ".next", ".nuxt", ".svelte-kit"} {
+		if _, err := os.Stat(filepath.Join(dir, out)); err == nil {
+			p.ignore = append(p.ignore, out)
+		}
+	}
+	if p.build != "" && len(p.ignore) == 1 {
+		// Nothing built yet; dist is the usual output directory.
+		p.ignore = append(p.ignore, "dist")
+		p.notes = append(p.notes, "assumed the build writes to dist; add its output directory to ignore if not")
+	}
+	return p, nil
+}
+
+var nodePortRe = regexp.MustCompile(`(?:--port[ =]|-p |PORT=)(\d{2,5})`)
+
+// detectPython proposes running the project's first script, a Django
+// manage.py, or its main module.
+func detectPython(dir string) (*proposal, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "pyproject.toml"))
+	if err != nil {
+		return nil, nil
+	}
+	var pyproject struct {
+		Project struct {
+			Scripts map[string]string `toml:"scripts"`
+		} `toml:"project"`
+	}
+	if err := toml.Unmarshal(data, &pyproject); err != nil {
+		return nil, fmt.Errorf("pyproject.toml: %v", err)
+	}
+
+	runner := ""
+	if _, err := os.Stat(filepath.Join(dir, "uv.lock")); err == nil {
+		runner = "uv run "
+	} else if _, err := os.Stat(filepath.Join(dir, "poetry.lock")); err == nil {
+		runner = "poetry run "
+	}
+	p := &proposal{kind: "a Python project", ignore: []string{"__pycache__", ".venv", "venv"}}
+
+	var scripts []string
+	for name := range pyproject.Project.Scripts {
+		scripts = append(scripts, name)
+	}
+	sort.Strings(scripts)
+	switch {
+	case fileExists(filepath.Join(dir, "manage.py")):
+		p.run = runner + "python manage.py runserver --noreload"
+		p.healthURL = "http://localhost:8000"
+	case len(scripts) > 0:
+		p.run = runner + scripts[0]
+	default:
+		for _, f := range []string{"app.py", "main.py", "server.py"} {
+			if fileExists(filepath.Join(dir, f)) {
+				p.run = runner + "python " + f
+				if port := findPort(filepath.Join(dir, f), ".py", pyPortRe); port != "" {
+					p.healthURL = "http://localhost:" + port
+				}
+				break
+			}
+		}
+	}
+	if p.run == "" {
+		return nil, errors.New("pyproject.toml found but no script, manage.py, app.py, main.py or server.py to run")
+	}
+	return p, nil
+}
+
+var pyPortRe = regexp.MustCompile(`port\s*=\s*(\d{2,5})`)
+
+// detectMake proposes the Makefile's build and run targets.
+func detectMake(dir string) (*proposal, error) {
+	f, err := os.Open(filepath.Join(dir, "Makefile"))
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	targets := make(map[string]bool)
+	var recipes bytes.Buffer
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if m := makeTargetRe.FindStringSubmatch(line); m != nil {
+			for _, t := range strings.Fields(m[1]) {
+				targets[t] = true
+			}
+		}
+		if strings.HasPrefix(line, "\t") {
+			recipes.WriteString(line + "\n")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	p := &proposal{kind: "a Makefile"}
+	for _, t := range []string{"build", "all"} {
+		if targets[t] {
+			p.build = "make " + t
+			break
+		}
+	}
+	for _, t := range []string{"run", "dev", "serve", "start"} {
+		if targets[t] {
+			p.run = "make " + t
+			break
+		}
+	}
+	if p.run == "" {
+		return nil, errors.New("Makefile has no run, dev, serve or start target")
+	}
+	// The build output is named in the recipes rather than the targets.
+	if out := buildOutput(recipes.String()); out != "" {
+		p.ignore = append(p.ignore, out)
+	}
+	return p, nil
+}
+
+var makeTargetRe = regexp.MustCompile(`^([A-Za-z0-9_][A-Za-z0-9_ .-]*):(?:[^=]|$)`)
+
+// buildOutput returns the name to ignore for the output of a build
+// command, taken from its -o flag: the first element of the output path,
+// since ignore rules match names rather than paths.
+func buildOutput(cmd string) string {
+	m := outputFlagRe.FindStringSubmatch(cmd)
+	if m == nil {
+		return ""
+	}
+	out := filepath.ToSlash(filepath.Clean(m[1]))
+	if out == "." || strings.HasPrefix(out, "..") || strings.HasPrefix(out, "/") || strings.Contains(out, "$") {
+		return ""
+	}
+	return strings.SplitN(out, "/", 2)[0]
+}
+
+var outputFlagRe = regexp.MustCompile(`(?:^|\s)-o[ =]?(\S+)`)
+
+// findPort returns the first port matched by re in the files with the
+// given extension directly in dir, or dir itself if it is a file.
+func findPort(dir, ext string, re *regexp.Regexp) string {
+	files := []string{dir}
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		files, _ = filepath.Glob(filepath.Join(dir, "*"+ext))
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		if m := re.FindSubmatch(data); m != nil {
+			return string(m[1])
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// render writes the proposal as a commented livereload.toml.
+func (p *proposal) render() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# livereload.toml for %s, written by \"livereload init\".\n", p.kind)
+	fmt.Fprintf(&b, "# Every setting is described in the README; \"livereload check\" validates this file.\n\n")
+
+	b.WriteString("# Builds the app before every restart.\n")
+	if p.build != "" {
+		fmt.Fprintf(&b, "build = %s\n\n", tomlQuote(p.build))
+	} else {
+		b.WriteString("# build = \"\"\n\n")
+	}
+	b.WriteString("# Runs the app. It is stopped and started again whenever a watched file changes.\n")
+	fmt.Fprintf(&b, "run = %s\n\n", tomlQuote(p.run))
+	b.WriteString("# Files and directories whose changes trigger a rebuild.\n")
+	fmt.Fprintf(&b, "watch = %s\n\n", tomlList(p.watch))
+	b.WriteString("# Names of files and directories that never trigger a rebuild. The build\n")
+	b.WriteString("# output must be among them, or every build would trigger the next one.\n")
+	fmt.Fprintf(&b, "ignore = %s\n\n", tomlList(p.ignore))
+	b.WriteString("# Polled after every restart; the browser reloads once it responds.\n")
+	if p.healthURL != "" {
+		fmt.Fprintf(&b, "health_url = %s\n", tomlQuote(p.healthURL))
+	} else {
+		b.WriteString("# health_url = \"http://localhost:8080\"\n")
+	}
+	return b.Bytes()
+}
+
+// tomlQuote returns s as a TOML basic string.
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func tomlList(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = tomlQuote(s)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
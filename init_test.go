@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files, given as path and content, under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, content)
+	}
+}
+
+func TestDetectProject(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		want  proposal
+	}{
+		{"go", map[string]string{
+			"go.mod":                "module example.com/shop\n",
+			"cmd/shop/main.go":      "package main\n\nfunc main() { http.ListenAndServe(\":9000\", nil) }\n",
+			"cmd/migrate/main.go":   "package main\n",
+			"internal/store/lib.go": "package store\n",
+		}, proposal{
+			build:     "go build -o tmp/shop ./cmd/shop",
+			run:       "./tmp/shop",
+			ignore:    []string{".git", "tmp"},
+			healthURL: "http://localhost:9000",
+		}},
+		{"node", map[string]string{
+			"package.json": `{"scripts": {"build": "tsc", "dev": "node server.js --port 4000"}}`,
+			"yarn.lock":    "",
+		}, proposal{
+			build:     "yarn run build",
+			run:       "yarn run dev",
+			ignore:    []string{".git", "node_modules", "dist"},
+			healthURL: "http://localhost:4000",
+		}},
+		{"python", map[string]string{
+			"pyproject.toml": "[project]\nname = \"app\"\n",
+			"uv.lock":        "",
+			"app.py":         "app.run(port=5001)\n",
+		}, proposal{
+			run:       "uv run python app.py",
+			ignore:    []string{".git", "__pycache__", ".venv", "venv"},
+			healthURL: "http://localhost:5001",
+		}},
+		{"make", map[string]string{
+			"Makefile": "build:\n\tcc -o bin/server server.c\n\nrun: build\n\t./bin/server\n",
+		}, proposal{
+			build:  "make build",
+			run:    "make run",
+			ignore: []string{".git", "bin"},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, tc.files)
+			p, err := detectProject(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.build != tc.want.build || p.run != tc.want.run || p.healthURL != tc.want.healthURL {
+				t.Errorf("Expected build %q, run %q, health_url %q; got %q, %q, %q",
+					tc.want.build, tc.want.run, tc.want.healthURL, p.build, p.run, p.healthURL)
+			}
+			if !reflect.DeepEqual(p.ignore, tc.want.ignore) {
+				t.Errorf("Expected ignore %v, got %v", tc.want.ignore, p.ignore)
+			}
+
+			// The written file loads and says the same.
+			path := writeFile(t, filepath.Join(dir, "livereload.toml"), string(p.render()))
+			cfg, err := loadConfig(path, "", defaultConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Build.Shell != p.build || cfg.Run.Shell != p.run || !reflect.DeepEqual(cfg.Ignore, p.ignore) {
+				t.Errorf("Rendered config differs from the proposal: %+v", cfg)
+			}
+		})
+	}
+
+	if _, err := detectProject(t.TempDir()); err == nil {
+		t.Error("Expected an error for an empty directory")
+	}
+}
+
+func TestRunInit_NoOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"go.mod": "module app\n", "main.go": "package main\n"})
+	chdir(t, dir)
+	stdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	t.Cleanup(func() { os.Stdout = stdout })
+
+	if err := runInit(false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := runInit(false, false); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("Expected existing livereload.toml to be kept, got %v", err)
+	}
+	if err := runInit(true, false); err != nil {
+		t.Errorf("Expected --force to overwrite, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package livereload
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the state of a session, as reported by GET /api/status.
+type Status struct {
+	PID        int          `json:"pid"` // of the running app, 0 if none
+	LastBuild  *BuildResult `json:"last_build,omitempty"`
+	Clients    int          `json:"clients"` // connected browsers
+	WatchRoots []string     `json:"watch_roots"`
+}
+
+// BuildResult describes the most recent build.
+type BuildResult struct {
+	OK         bool      `json:"ok"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// session holds the status the Run loop updates for the API.
+type session struct {
+	mu        sync.Mutex
+	pid       int
+	lastBuild *BuildResult
+}
+
+// pider is implemented by processes that know their PID.
+type pider interface {
+	Pid() int
+}
+
+func (p *RealProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// NewToken returns a random token for APIToken.
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (app *Livereload) recordBuild(start time.Time, err error) {
+	r := &BuildResult{OK: err == nil, Time: start, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	app.session.mu.Lock()
+	app.session.lastBuild = r
+	app.session.mu.Unlock()
+}
+
+func (app *Livereload) recordProcess(p Process) {
+	pid := 0
+	if p, ok := p.(pider); ok {
+		pid = p.Pid()
+	}
+	app.session.mu.Lock()
+	app.session.pid = pid
+	app.session.mu.Unlock()
+}
+
+// Status returns the current state of the session.
+func (app *Livereload) Status() Status {
+	app.session.mu.Lock()
+	s := Status{PID: app.session.pid, LastBuild: app.session.lastBuild}
+	app.session.mu.Unlock()
+
+	app.Hub.mu.Lock()
+	s.Clients = len(app.Hub.clients)
+	app.Hub.mu.Unlock()
+
+	app.mu.RLock()
+	s.WatchRoots = append([]string{}, app.WatchRoots...)
+	app.mu.RUnlock()
+	return s
+}
+
+// handleAPI registers the control API on mux. Every request must carry
+// APIToken as a bearer token and, if sent from a page, come from an
+// allowed origin.
+func (app *Livereload) handleAPI(mux *http.ServeMux) {
+	action := func(a Action) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			app.Do(a)
+			writeJSON(w, http.StatusAccepted, map[string]bool{"ok": true})
+		}
+	}
+	mux.Handle("POST /api/reload", app.authorize(action(ActionReload)))
+	mux.Handle("POST /api/restart", app.authorize(action(ActionRestart)))
+	mux.Handle("POST /api/build", app.authorize(action(ActionRebuild)))
+	mux.Handle("GET /api/status", app.authorize(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, app.Status())
+	}))
+}
+
+func (app *Livereload) authorize(h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.originAllowed(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "origin not allowed"})
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(app.APIToken)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong token"})
+			return
+		}
+		h(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,67 @@
+package livereload
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAPI(t *testing.T) {
+	app := &Livereload{Hub: NewReloadHub(), APIToken: "secret", WatchRoots: []string{"src"}}
+	app.recordBuild(time.Now(), errors.New("exit status 1"))
+	mux := http.NewServeMux()
+	app.handleAPI(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, path, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if resp := do("GET", "/api/status", token); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for token %q, got %d", token, resp.StatusCode)
+		}
+	}
+	if resp := do("GET", "/api/reload", "secret"); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET /api/reload, got %d", resp.StatusCode)
+	}
+
+	resp := do("GET", "/api/status", "secret")
+	var s Status
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.LastBuild == nil || s.LastBuild.OK || s.LastBuild.Error != "exit status 1" {
+		t.Errorf("Expected failed last build, got %+v", s.LastBuild)
+	}
+	if !reflect.DeepEqual(s.WatchRoots, []string{"src"}) || s.PID != 0 {
+		t.Errorf("Unexpected status: %+v", s)
+	}
+
+	for path, want := range map[string]Action{
+		"/api/reload":  ActionReload,
+		"/api/restart": ActionRestart,
+		"/api/build":   ActionRebuild,
+	} {
+		if resp := do("POST", path, "secret"); resp.StatusCode != http.StatusAccepted {
+			t.Errorf("%s: expected 202, got %d", path, resp.StatusCode)
+		}
+		if a := <-app.restartCh; a != want {
+			t.Errorf("%s: expected action %v, got %v", path, want, a)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package livereload
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ParseEnvFile reads a dotenv file and returns the variables it defines.
+// Blank lines and lines starting with # are skipped, an optional "export "
+// prefix is accepted, and values may be single or double quoted. Double
+// quoted values support the \n, \t, \" and \\ escapes.
+func ParseEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func parseEnvValue(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	switch quote := v[0]; quote {
+	case '\'', '"':
+		end := strings.LastIndexByte(v, quote)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated %c quote", quote)
+		}
+		inner := v[1:end]
+		if quote == '\'' {
+			return inner, nil
+		}
+		r := strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+		return r.Replace(inner), nil
+	}
+	// Unquoted values may carry a trailing comment.
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v, nil
+}
+
+// environ returns the environment for child processes: livereload's own
+// environment, overlaid with the env files in order and then the Env map.
+// The env files are re-read on every call so edits take effect on the next
+// restart.
+func (r *RealCommandRunner) environ() ([]string, error) {
+	if len(r.EnvFiles) == 0 && len(r.Env) == 0 {
+		return nil, nil // inherit
+	}
+	merged := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			merged[k] = v
+		}
+	}
+	for _, path := range r.EnvFiles {
+		vars, err := ParseEnvFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("env file: %w", err)
+		}
+		for k, v := range vars {
+			merged[k] = v
+		}
+	}
+	for k, v := range r.Env {
+		merged[k] = v
+	}
+
+	env := make([]string, 0, len(merged))
+	for k, v := range merged {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env, nil
+}
@@ -0,0 +1,77 @@
+package livereload
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	content := `# comment
+PLAIN=value
+export EXPORTED=yes
+SPACED = padded  # trailing comment
+SINGLE='it''s $HOME'
+DOUBLE="line1\nline2"
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := ParseEnvFile(path)
+	if err != nil {
+		t.Fatalf("ParseEnvFile: %v", err)
+	}
+	want := map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "yes",
+		"SPACED":   "padded",
+		"SINGLE":   "it''s $HOME",
+		"DOUBLE":   "line1\nline2",
+		"EMPTY":    "",
+	}
+	if len(vars) != len(want) {
+		t.Errorf("Expected %d vars, got %d: %v", len(want), len(vars), vars)
+	}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, vars[k])
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("NOEQUALS\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseEnvFile(path); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("Expected error with line number, got %v", err)
+	}
+}
+
+func TestRealCommandRunner_DirAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	if err := os.WriteFile(envFile, []byte("FROM_FILE=file\nOVERRIDDEN=file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &RealCommandRunner{
+		Dir:      dir,
+		Env:      map[string]string{"OVERRIDDEN": "map"},
+		EnvFiles: []string{envFile},
+	}
+	if err := r.Run(`echo "$FROM_FILE $OVERRIDDEN" > out.txt`); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	out, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatalf("Expected command to run in Dir: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "file map" {
+		t.Errorf("Expected %q, got %q", "file map", got)
+	}
+}
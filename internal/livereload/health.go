@@ -0,0 +1,170 @@
+package livereload
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// Probe checks whether the running process is ready to serve. Check is
+// polled every HealthInterval after a restart and returns nil once the
+// process is ready.
+type Probe interface {
+	Check() error
+}
+
+// resetter is implemented by probes that keep state between restarts.
+type resetter interface {
+	Reset()
+}
+
+// HTTPProbe is ready when a GET of URL succeeds with the expected status
+// and, if Body is set, a response body matching it.
+type HTTPProbe struct {
+	URL    string
+	Status int            // expected status; 0 accepts any 2xx or 3xx
+	Body   *regexp.Regexp // optional
+	Client *http.Client   // defaults to a client with a one second timeout
+}
+
+func (p *HTTPProbe) Check() error {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Second}
+	}
+	resp, err := client.Get(p.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if p.Status != 0 {
+		if resp.StatusCode != p.Status {
+			return fmt.Errorf("%s returned %d, want %d", p.URL, resp.StatusCode, p.Status)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %d", p.URL, resp.StatusCode)
+	}
+	if p.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return err
+		}
+		if !p.Body.Match(body) {
+			return fmt.Errorf("%s body does not match %q", p.URL, p.Body)
+		}
+	}
+	return nil
+}
+
+// TCPProbe is ready when a TCP connection to Addr can be opened.
+type TCPProbe struct {
+	Addr string
+}
+
+func (p *TCPProbe) Check() error {
+	conn, err := net.DialTimeout("tcp", p.Addr, time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// CommandProbe is ready when Cmd, run with sh -c in Dir, exits 0. Like the
+// build and run commands, it gets the environment described by EnvFiles
+// and Env. Its output is discarded.
+type CommandProbe struct {
+	Cmd      string
+	Dir      string
+	Env      map[string]string
+	EnvFiles []string
+}
+
+func (p *CommandProbe) Check() error {
+	env, err := (&RealCommandRunner{Env: p.Env, EnvFiles: p.EnvFiles}).environ()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", p.Cmd)
+	cmd.Dir = p.Dir
+	cmd.Env = env
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%q: %v", p.Cmd, err)
+	}
+	return nil
+}
+
+// LogProbe is ready once a line of the process's output matches Pattern.
+// Lines are fed to it with Observe, usually from Output.OnLine.
+type LogProbe struct {
+	Pattern *regexp.Regexp
+	matched atomic.Bool
+}
+
+func (p *LogProbe) Observe(line []byte) {
+	if p.Pattern.Match(line) {
+		p.matched.Store(true)
+	}
+}
+
+func (p *LogProbe) Reset() {
+	p.matched.Store(false)
+}
+
+func (p *LogProbe) Check() error {
+	if !p.matched.Load() {
+		return fmt.Errorf("no output line matched %q yet", p.Pattern)
+	}
+	return nil
+}
+
+// AllProbes is ready when every one of its probes is.
+type AllProbes []Probe
+
+func (ps AllProbes) Check() error {
+	for _, p := range ps {
+		if err := p.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ps AllProbes) Reset() {
+	for _, p := range ps {
+		if r, ok := p.(resetter); ok {
+			r.Reset()
+		}
+	}
+}
+
+// HealthPolicy decides what happens when the health check times out.
+type HealthPolicy string
+
+const (
+	HealthReload  HealthPolicy = "reload"  // reload the browser anyway
+	HealthSkip    HealthPolicy = "skip"    // leave the browser alone
+	HealthRestart HealthPolicy = "restart" // restart the process and check again
+	HealthOverlay HealthPolicy = "overlay" // show an error overlay in the browser
+)
+
+// maxHealthRestarts bounds the restarts made by HealthRestart, so a broken
+// app isn't restarted forever.
+const maxHealthRestarts = 3
+
+// ParseHealthPolicy converts a config value to a HealthPolicy. The empty
+// string selects HealthReload.
+func ParseHealthPolicy(s string) (HealthPolicy, error) {
+	switch p := HealthPolicy(s); p {
+	case "":
+		return HealthReload, nil
+	case HealthReload, HealthSkip, HealthRestart, HealthOverlay:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown health policy %q (want reload, skip, restart or overlay)", s)
+}
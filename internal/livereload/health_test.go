@@ -0,0 +1,167 @@
+package livereload
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"status":"serving"}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		probe *HTTPProbe
+		ok    bool
+	}{
+		{"any 2xx", &HTTPProbe{URL: server.URL}, true},
+		{"expected status", &HTTPProbe{URL: server.URL, Status: http.StatusAccepted}, true},
+		{"wrong status", &HTTPProbe{URL: server.URL, Status: http.StatusOK}, false},
+		{"body match", &HTTPProbe{URL: server.URL, Body: regexp.MustCompile(`"serving"`)}, true},
+		{"body mismatch", &HTTPProbe{URL: server.URL, Body: regexp.MustCompile(`"stopped"`)}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.probe.Check(); (err == nil) != tt.ok {
+			t.Errorf("%s: Check() = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	probe := &TCPProbe{Addr: addr}
+	if err := probe.Check(); err != nil {
+		t.Errorf("Expected open port to pass, got %v", err)
+	}
+	ln.Close()
+	if err := probe.Check(); err == nil {
+		t.Error("Expected closed port to fail")
+	}
+}
+
+func TestCommandProbe(t *testing.T) {
+	if err := (&CommandProbe{Cmd: "exit 0"}).Check(); err != nil {
+		t.Errorf("Expected exit 0 to pass, got %v", err)
+	}
+	if err := (&CommandProbe{Cmd: "exit 3"}).Check(); err == nil {
+		t.Error("Expected exit 3 to fail")
+	}
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte("PORT=8123\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &CommandProbe{Cmd: `test "$PORT $MODE" = "8123 dev"`, Env: map[string]string{"MODE": "dev"}, EnvFiles: []string{envFile}}
+	if err := p.Check(); err != nil {
+		t.Errorf("Expected the probe to get the env and env files, got %v", err)
+	}
+}
+
+func TestLogProbe(t *testing.T) {
+	logProbe := &LogProbe{Pattern: regexp.MustCompile(`listening on :\d+`)}
+	probes := AllProbes{logProbe, &CommandProbe{Cmd: "true"}}
+
+	if err := probes.Check(); err == nil {
+		t.Error("Expected probe to fail before any output")
+	}
+	logProbe.Observe([]byte("starting up"))
+	if err := probes.Check(); err == nil {
+		t.Error("Expected probe to fail on non-matching line")
+	}
+	logProbe.Observe([]byte("grpc server listening on :9090"))
+	if err := probes.Check(); err != nil {
+		t.Errorf("Expected probe to pass after match, got %v", err)
+	}
+	probes.Reset()
+	if err := probes.Check(); err == nil {
+		t.Error("Expected probe to fail after Reset")
+	}
+}
+
+// startHookRunner calls onStart each time a process is started.
+type startHookRunner struct {
+	MockCommandRunner
+	onStart func()
+}
+
+func (r *startHookRunner) Start(cmd string) (Process, error) {
+	r.onStart()
+	return r.MockCommandRunner.Start(cmd)
+}
+
+func TestAwaitReady_Policies(t *testing.T) {
+	tests := []struct {
+		policy HealthPolicy
+		// healthyAfter is the number of starts after which the server
+		// reports healthy; 0 means never.
+		healthyAfter int
+		wantMsg      string
+		wantStarts   int
+	}{
+		{policy: HealthReload, healthyAfter: 1, wantMsg: "reload", wantStarts: 1},
+		{policy: HealthReload, wantMsg: "reload", wantStarts: 1},
+		{policy: HealthSkip, wantMsg: "", wantStarts: 1},
+		{policy: HealthOverlay, wantMsg: "error:Health check failed", wantStarts: 1},
+		{policy: HealthRestart, healthyAfter: 2, wantMsg: "reload", wantStarts: 2},
+		{policy: HealthRestart, wantMsg: "", wantStarts: 1 + maxHealthRestarts},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/healthyAfter=%d", tt.policy, tt.healthyAfter), func(t *testing.T) {
+			var starts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.healthyAfter == 0 || int(starts.Load()) < tt.healthyAfter {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer server.Close()
+
+			runner := &startHookRunner{onStart: func() { starts.Add(1) }}
+			app := &Livereload{
+				Runner:         runner,
+				RunCmd:         "./app",
+				Log:            log.New(io.Discard, "", 0),
+				HealthURL:      server.URL,
+				HealthTimeout:  100 * time.Millisecond,
+				HealthInterval: 10 * time.Millisecond,
+				HealthPolicy:   tt.policy,
+			}
+
+			p, err := app.launch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, msg := app.awaitReady(p)
+			if p == nil {
+				t.Error("Expected a running process")
+			}
+			var got string
+			if msg != nil {
+				got = string(msg.encode(0))
+			}
+			if !strings.HasPrefix(got, tt.wantMsg) || (tt.wantMsg == "") != (msg == nil) {
+				t.Errorf("Expected message %q, got %q", tt.wantMsg, got)
+			}
+			if got := int(starts.Load()); got != tt.wantStarts {
+				t.Errorf("Expected %d starts, got %d", tt.wantStarts, got)
+			}
+		})
+	}
+}
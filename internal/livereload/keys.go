@@ -0,0 +1,54 @@
+package livereload
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// keyHelp describes the keys understood by HandleKeys.
+const keyHelp = `>> Keys: r restart, b rebuild, R reload browser, c clear, q quit, h help`
+
+// HandleKeys reads single-key commands from r, usually a terminal's stdin
+// in cbreak mode, and asks the Run loop to act on them:
+//
+//	r  restart the process without building
+//	b  build and restart the process
+//	R  reload the browsers only
+//	c  clear the screen
+//	q  stop the process and quit
+//	h  print the keys
+//
+// Other bytes, including the newlines of a line-buffered terminal, are
+// ignored. HandleKeys returns when r is exhausted.
+func (app *Livereload) HandleKeys(r io.Reader) error {
+	fmt.Println(keyHelp)
+	br := bufio.NewReader(r)
+	for {
+		key, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch key {
+		case 'r':
+			fmt.Println(">> Restart requested")
+			app.Do(ActionRestart)
+		case 'b':
+			fmt.Println(">> Rebuild requested")
+			app.Do(ActionRebuild)
+		case 'R':
+			app.Do(ActionReload)
+		case 'c':
+			// Move home, clear the screen and the scrollback.
+			fmt.Print("\x1b[H\x1b[2J\x1b[3J")
+		case 'q':
+			fmt.Println(">> Quitting")
+			app.Do(ActionQuit)
+		case 'h', '?':
+			fmt.Println(keyHelp)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package livereload
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDo_MergesPendingActions(t *testing.T) {
+	app := &Livereload{}
+	app.Do(ActionReload)
+	app.Do(ActionRebuild)
+	app.Do(ActionRestart)
+	if a := <-app.restartCh; a != ActionRebuild {
+		t.Errorf("Expected pending actions to merge into a rebuild, got %v", a)
+	}
+}
+
+func TestHandleKeys(t *testing.T) {
+	mockRunner := &MockCommandRunner{}
+	mockProcess := &MockProcess{}
+	mockRunner.MockProcess = mockProcess
+	app := &Livereload{
+		Watcher:      NewMockWatcher(),
+		Runner:       mockRunner,
+		BuildCmd:     "go build",
+		RunCmd:       "./app",
+		IgnoreMap:    make(map[string]bool),
+		DebounceTime: 10 * time.Millisecond,
+		Log:          log.New(io.Discard, "", 0),
+		Hub:          NewReloadHub(),
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	time.Sleep(50 * time.Millisecond)
+
+	// r restarts without building.
+	app.HandleKeys(strings.NewReader("r\n"))
+	time.Sleep(50 * time.Millisecond)
+	if runs, starts := mockRunner.Runs(), mockRunner.Starts(); len(runs) != 1 || len(starts) != 2 {
+		t.Errorf("Expected 1 build and 2 runs after r, got %v and %v", runs, starts)
+	}
+
+	// b builds and restarts.
+	app.HandleKeys(strings.NewReader("b"))
+	time.Sleep(50 * time.Millisecond)
+	if runs, starts := mockRunner.Runs(), mockRunner.Starts(); len(runs) != 2 || len(starts) != 3 {
+		t.Errorf("Expected 2 builds and 3 runs after b, got %v and %v", runs, starts)
+	}
+
+	// R only reloads the browsers.
+	app.HandleKeys(strings.NewReader("R"))
+	time.Sleep(50 * time.Millisecond)
+	if runs, starts := mockRunner.Runs(), mockRunner.Starts(); len(runs) != 2 || len(starts) != 3 {
+		t.Errorf("Expected no build or run after R, got %v and %v", runs, starts)
+	}
+
+	// q stops the process and returns from Run.
+	mockProcess.Reset()
+	app.HandleKeys(strings.NewReader("q"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("Expected Run to return nil, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return after q")
+	}
+	if killed, _ := mockProcess.Called(); !killed {
+		t.Error("Expected the process to be stopped on quit")
+	}
+}
@@ -1,6 +1,7 @@
 package livereload
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -16,6 +18,7 @@ import (
 // FileWatcher interface abstraction for fsnotify.Watcher
 type FileWatcher interface {
 	Add(name string) error
+	Remove(name string) error
 	Close() error
 	Events() chan fsnotify.Event
 	Errors() chan error
@@ -34,10 +37,14 @@ func (w *RealWatcher) Errors() chan error {
 	return w.Watcher.Errors
 }
 
-// CommandRunner interface for running commands
+// CommandRunner interface for running commands. Run and Start take a shell
+// command line, RunArgs and StartArgs an argv executed directly without a
+// shell.
 type CommandRunner interface {
 	Run(cmd string) error
 	Start(cmd string) (Process, error)
+	RunArgs(argv []string) error
+	StartArgs(argv []string) (Process, error)
 }
 
 // Process interface for controlling a running process
@@ -46,36 +53,122 @@ type Process interface {
 	Wait() error
 }
 
-// RealCommandRunner implements CommandRunner using exec.Command
-type RealCommandRunner struct{}
+// RealCommandRunner implements CommandRunner using exec.Command.
+// Commands run in Dir (the current directory if empty) with the
+// environment described by EnvFiles and Env. If Output is set, their
+// stdout and stderr are labelled "build" or "run" and written through it;
+// otherwise they go straight to livereload's own stdout and stderr.
+//
+// Processes started by Start and StartArgs run in a process group of their
+// own, so that killing them also kills whatever they forked, such as the
+// server started by "go run" or "npm run dev".
+type RealCommandRunner struct {
+	Dir      string
+	Env      map[string]string
+	EnvFiles []string
+	Output   *Output
+
+	mu      sync.Mutex
+	running map[*RealProcess]bool // started and not yet waited for
+}
+
+// command prepares argv for execution. The returned flush function must be
+// called after the command has exited.
+func (r *RealCommandRunner) command(label string, argv []string) (*exec.Cmd, func(), error) {
+	if len(argv) == 0 {
+		return nil, nil, fmt.Errorf("empty command")
+	}
+	env, err := r.environ()
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = r.Dir
+	cmd.Env = env
+	if r.Output == nil {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd, func() {}, nil
+	}
+	stdout := r.Output.Writer(label, false)
+	stderr := r.Output.Writer(label, true)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// A grandchild that left the process group may keep the pipes open;
+	// don't let that block Wait forever.
+	cmd.WaitDelay = 2 * time.Second
+	return cmd, func() {
+		stdout.Flush()
+		stderr.Flush()
+	}, nil
+}
 
 func (r *RealCommandRunner) Run(cmdStr string) error {
-	cmd := exec.Command("sh", "-c", cmdStr)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return r.RunArgs([]string{"sh", "-c", cmdStr})
 }
 
 func (r *RealCommandRunner) Start(cmdStr string) (Process, error) {
-	cmd := exec.Command("sh", "-c", cmdStr)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	return r.StartArgs([]string{"sh", "-c", cmdStr})
+}
+
+func (r *RealCommandRunner) RunArgs(argv []string) error {
+	cmd, flush, err := r.command("build", argv)
+	if err != nil {
+		return err
+	}
+	defer flush()
+	return cmd.Run()
+}
+
+func (r *RealCommandRunner) StartArgs(argv []string) (Process, error) {
+	cmd, flush, err := r.command("run", argv)
+	if err != nil {
+		return nil, err
+	}
+	setProcessGroup(cmd)
 	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
-	return &RealProcess{cmd}, nil
+	p := &RealProcess{cmd: cmd, flush: flush, runner: r}
+	r.mu.Lock()
+	if r.running == nil {
+		r.running = make(map[*RealProcess]bool)
+	}
+	r.running[p] = true
+	r.mu.Unlock()
+	return p, nil
+}
+
+// Stop kills the processes started by Start and StartArgs that are still
+// running. Being in their own process groups, they don't get the Ctrl-C
+// that interrupts livereload, so call it before exiting.
+func (r *RealCommandRunner) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p := range r.running {
+		p.Kill()
+	}
 }
 
 // RealProcess wraps exec.Cmd
 type RealProcess struct {
-	cmd *exec.Cmd
+	cmd    *exec.Cmd
+	flush  func()
+	runner *RealCommandRunner
 }
 
+// Kill kills the process and everything in its process group.
 func (p *RealProcess) Kill() error {
-	return p.cmd.Process.Kill()
+	return killProcessGroup(p.cmd)
 }
 
 func (p *RealProcess) Wait() error {
+	defer p.flush()
+	defer func() {
+		p.runner.mu.Lock()
+		delete(p.runner.running, p)
+		p.runner.mu.Unlock()
+	}()
 	return p.cmd.Wait()
 }
 
@@ -85,6 +178,8 @@ type Livereload struct {
 	Runner         CommandRunner
 	BuildCmd       string
 	RunCmd         string
+	BuildArgs      []string // if set, used instead of BuildCmd
+	RunArgs        []string // if set, used instead of RunCmd
 	IgnoreMap      map[string]bool
 	DebounceTime   time.Duration
 	RestartDelay   time.Duration
@@ -94,8 +189,47 @@ type Livereload struct {
 	Hub            *ReloadHub
 	LivereloadJS   []byte
 	HealthURL      string
+	Health         Probe // if set, used instead of HealthURL
 	HealthTimeout  time.Duration
 	HealthInterval time.Duration
+	HealthPolicy   HealthPolicy
+
+	// ConfigFile is the path of the config file. Changes to it call
+	// OnConfigChange instead of restarting the process.
+	ConfigFile     string
+	OnConfigChange func()
+
+	// APIToken, if set, enables the control API under /api; requests
+	// must carry it as a bearer token. WatchRoots are the watched paths
+	// it reports.
+	APIToken   string
+	WatchRoots []string
+
+	// Static, if set, serves everything but the livereload paths, for
+	// example with a StaticServer. RunCmd is then optional.
+	Static http.Handler
+
+	// Routes say which pages a change to a file affects. Reloads only go
+	// to those, or to every page if no route matches.
+	Routes []Route
+
+	// AllowedOrigins are the pages that may connect, as matched by
+	// OriginMatches, besides those served by the server itself. If empty,
+	// only pages from LocalHosts may. Their hosts may also be used to reach
+	// the server.
+	AllowedOrigins []string
+
+	// TLSCert and TLSKey, if set, are the certificate and key files the
+	// server uses to serve HTTPS and WSS.
+	TLSCert string
+	TLSKey  string
+
+	mu         sync.RWMutex // guards settings changed by Reconfigure
+	initOnce   sync.Once
+	session    session   // status reported by the API
+	changes    changeSet // files changed since the last build
+	restartCh  chan Action
+	reconfigCh chan func(*Livereload)
 }
 
 func NewLivereload(buildCmd, runCmd string, ignoreMap map[string]bool, watcher FileWatcher, reloadPort int, reloadHost string, livereloadJS []byte) *Livereload {
@@ -115,14 +249,28 @@ func NewLivereload(buildCmd, runCmd string, ignoreMap map[string]bool, watcher F
 		HealthURL:      "",
 		HealthTimeout:  5 * time.Second,
 		HealthInterval: 50 * time.Millisecond,
+		HealthPolicy:   HealthReload,
 	}
 }
 
-// waitForHealth polls the HealthURL until it returns 200 OK or times out.
+// healthProbe returns the configured readiness probe: Health if set, an
+// HTTPProbe for HealthURL otherwise, or nil if neither is configured.
+func (app *Livereload) healthProbe() Probe {
+	if app.Health != nil {
+		return app.Health
+	}
+	if app.HealthURL != "" {
+		return &HTTPProbe{URL: app.HealthURL, Client: &http.Client{Timeout: app.HealthInterval}}
+	}
+	return nil
+}
+
+// waitForHealth polls the health probe until it passes or times out.
 // Returns nil if health check passes, error if it times out.
 func (app *Livereload) waitForHealth() error {
-	if app.HealthURL == "" {
-		// No health URL configured, fall back to delay
+	probe := app.healthProbe()
+	if probe == nil {
+		// No health check configured, fall back to delay
 		if app.RestartDelay > 0 {
 			time.Sleep(app.RestartDelay)
 		}
@@ -130,32 +278,181 @@ func (app *Livereload) waitForHealth() error {
 	}
 
 	deadline := time.Now().Add(app.HealthTimeout)
-	client := &http.Client{Timeout: app.HealthInterval}
-
+	var err error
 	for time.Now().Before(deadline) {
-		resp, err := client.Get(app.HealthURL)
-		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-				return nil
-			}
+		if err = probe.Check(); err == nil {
+			return nil
 		}
 		time.Sleep(app.HealthInterval)
 	}
+	if err == nil {
+		err = fmt.Errorf("no check completed")
+	}
+	return fmt.Errorf("timed out after %v: %w", app.HealthTimeout, err)
+}
+
+// awaitReady waits for the process p to pass its health check and applies
+// HealthPolicy if it does not. It returns the running process, which is a
+// new one if the policy restarted it, and the message to broadcast to the
+// browsers, or nil if they should be left alone.
+func (app *Livereload) awaitReady(p Process) (Process, *Message) {
+	err := app.waitForHealth()
+	if err == nil {
+		return p, &Message{Type: MessageReload}
+	}
+	fmt.Printf(">> Health check failed: %v\n", err)
+
+	switch app.HealthPolicy {
+	case HealthSkip:
+		fmt.Println(">> Not reloading the browser")
+		return p, nil
+	case HealthOverlay:
+		return p, &Message{Type: MessageError, Error: "Health check failed: " + err.Error()}
+	case HealthRestart:
+		for attempt := 1; attempt <= maxHealthRestarts; attempt++ {
+			fmt.Printf(">> Restarting (attempt %d of %d)...\n", attempt, maxHealthRestarts)
+			app.stop(p)
+			if p, err = app.launch(); err != nil {
+				fmt.Printf(">> Run failed: %v\n", err)
+				return nil, nil
+			}
+			if err = app.waitForHealth(); err == nil {
+				return p, &Message{Type: MessageReload}
+			}
+			fmt.Printf(">> Health check failed: %v\n", err)
+		}
+		fmt.Println(">> Giving up, not reloading the browser")
+		return p, nil
+	default:
+		fmt.Println(">> Reloading the browser anyway")
+		return p, &Message{Type: MessageReload}
+	}
+}
+
+// stop kills p and waits for it to exit.
+func (app *Livereload) stop(p Process) {
+	if err := p.Kill(); err != nil {
+		// Optimization: ignore "process already finished" errors
+		if !strings.Contains(err.Error(), "process already finished") && !strings.Contains(err.Error(), "os: process already finished") {
+			app.Log.Printf("Failed to kill process: %v", err)
+		}
+	}
+	if err := p.Wait(); err != nil {
+		// Ignore signal killed errors as they are expected
+		if !strings.Contains(err.Error(), "signal: killed") && !strings.Contains(err.Error(), "process already finished") {
+			app.Log.Printf("Process finished with error: %v", err)
+		}
+	}
+}
+
+// launch starts a new process after resetting the health probe.
+func (app *Livereload) launch() (Process, error) {
+	// Forget readiness observed from the previous process.
+	if r, ok := app.healthProbe().(resetter); ok {
+		r.Reset()
+	}
+	fmt.Println(">> Running...")
+	return app.start()
+}
+
+// build runs the build command, preferring BuildArgs over BuildCmd.
+func (app *Livereload) build() error {
+	if len(app.BuildArgs) > 0 {
+		return app.Runner.RunArgs(app.BuildArgs)
+	}
+	return app.Runner.Run(app.BuildCmd)
+}
+
+// start starts the run command, preferring RunArgs over RunCmd.
+func (app *Livereload) start() (Process, error) {
+	if len(app.RunArgs) > 0 {
+		return app.Runner.StartArgs(app.RunArgs)
+	}
+	return app.Runner.Start(app.RunCmd)
+}
+
+func (app *Livereload) init() {
+	app.initOnce.Do(func() {
+		// Channel to signal a rebuild/restart is needed
+		app.restartCh = make(chan Action, 1)
+		app.reconfigCh = make(chan func(*Livereload))
+	})
+}
+
+// Action is something the Run loop can be asked to do. When several are
+// requested before the loop gets to them, only the greatest is done, since
+// it includes the others.
+type Action int
+
+const (
+	ActionReload  Action = iota + 1 // reload the browsers only
+	ActionRestart                   // restart the process without building
+	ActionRebuild                   // build and restart the process
+	ActionQuit                      // stop the process and return from Run
+)
+
+// Restart asks the Run loop to rebuild and restart the process.
+func (app *Livereload) Restart() {
+	app.Do(ActionRebuild)
+}
+
+// Do asks the Run loop to perform a. It does not wait for a to be done.
+func (app *Livereload) Do(a Action) {
+	app.init()
+	for {
+		select {
+		case app.restartCh <- a:
+			return
+		default:
+		}
+		// An action is already pending; merge it with a.
+		select {
+		case pending := <-app.restartCh:
+			a = max(a, pending)
+		default:
+		}
+	}
+}
+
+// Reconfigure runs fn on the Run loop between two cycles, so fn may change
+// any of the app's settings, and then rebuilds and restarts the process.
+func (app *Livereload) Reconfigure(fn func(app *Livereload)) {
+	app.init()
+	app.reconfigCh <- fn
+}
 
-	app.Log.Printf("Warning: Health check timed out after %v", app.HealthTimeout)
-	return nil // Still proceed with reload even if health check fails
+// isIgnored reports whether events for the file at path should be skipped.
+func (app *Livereload) isIgnored(path string) bool {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	return app.IgnoreMap[filepath.Base(path)]
+}
+
+// debounceTime returns DebounceTime, which Reconfigure may change.
+func (app *Livereload) debounceTime() time.Duration {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	return app.DebounceTime
+}
+
+// isConfigFile reports whether path names the config file.
+func (app *Livereload) isConfigFile(path string) bool {
+	if app.ConfigFile == "" || app.OnConfigChange == nil {
+		return false
+	}
+	a, err1 := filepath.Abs(path)
+	b, err2 := filepath.Abs(app.ConfigFile)
+	return err1 == nil && err2 == nil && a == b
 }
 
 func (app *Livereload) Run() error {
+	app.init()
+
 	// Start the reload server
 	app.StartServer()
 
-	// Channel to signal a rebuild/restart is needed
-	restartCh := make(chan bool, 1)
-
-	// Debounce timer
-	var debounceTimer *time.Timer
+	// Debounce timers
+	var debounceTimer, configTimer *time.Timer
 
 	go func() {
 		for {
@@ -164,20 +461,28 @@ func (app *Livereload) Run() error {
 				if !ok {
 					return
 				}
+				if app.isConfigFile(event.Name) {
+					if configTimer != nil {
+						configTimer.Stop()
+					}
+					configTimer = time.AfterFunc(app.debounceTime(), func() {
+						app.Log.Printf("Modified config file: %s", event.Name)
+						app.OnConfigChange()
+					})
+					continue
+				}
 				// Skip ignored files
-				if app.IgnoreMap[filepath.Base(event.Name)] {
+				if app.isIgnored(event.Name) {
 					continue
 				}
 				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create || event.Op&fsnotify.Remove == fsnotify.Remove {
+					app.changes.add(event.Name)
 					if debounceTimer != nil {
 						debounceTimer.Stop()
 					}
-					debounceTimer = time.AfterFunc(app.DebounceTime, func() {
+					debounceTimer = time.AfterFunc(app.debounceTime(), func() {
 						app.Log.Printf("Modified file: %s", event.Name)
-						select {
-						case restartCh <- true:
-						default:
-						}
+						app.Restart()
 					})
 				}
 			case err, ok := <-app.Watcher.Errors():
@@ -190,56 +495,113 @@ func (app *Livereload) Run() error {
 	}()
 
 	// Initial run
-	restartCh <- true
+	app.Restart()
 
 	var currentProcess Process
+	var buildID int64
+
+	for {
+		action := ActionRebuild
+		select {
+		case action = <-app.restartCh:
+		case fn := <-app.reconfigCh:
+			app.mu.Lock()
+			fn(app)
+			app.mu.Unlock()
+			fmt.Println(">> Configuration reloaded")
+		}
+
+		if action == ActionReload {
+			fmt.Println(">> Reloading the browser")
+			app.Hub.Broadcast(Message{Type: MessageReload, BuildID: buildID})
+			continue
+		}
+		changed, since := app.changes.take()
 
-	for range restartCh {
 		if currentProcess != nil {
-			if err := currentProcess.Kill(); err != nil {
-				// Optimization: ignore "process already finished" errors
-				if !strings.Contains(err.Error(), "process already finished") && !strings.Contains(err.Error(), "os: process already finished") {
-					app.Log.Printf("Failed to kill process: %v", err)
-				}
+			app.stop(currentProcess)
+			currentProcess = nil
+			app.recordProcess(nil)
+		}
+		if action == ActionQuit {
+			return nil
+		}
+
+		// The browsers show the progress of each cycle.
+		buildID++
+		state := func(t MessageType, err error) {
+			m := Message{Type: t, BuildID: buildID, Changed: changed}
+			if t == MessageReady || t == MessageFailed {
+				m.DurationMS = time.Since(since).Milliseconds()
 			}
-			if err := currentProcess.Wait(); err != nil {
-				// Ignore signal killed errors as they are expected
-				if !strings.Contains(err.Error(), "signal: killed") && !strings.Contains(err.Error(), "process already finished") {
-					app.Log.Printf("Process finished with error: %v", err)
-				}
+			if err != nil {
+				m.Error = err.Error()
 			}
+			app.Hub.Broadcast(m)
 		}
 
-		if app.BuildCmd != "" {
+		if action == ActionRebuild && (app.BuildCmd != "" || len(app.BuildArgs) > 0) {
 			fmt.Println(">> Building...")
-			if err := app.Runner.Run(app.BuildCmd); err != nil {
+			state(MessageBuilding, nil)
+			start := time.Now()
+			err := app.build()
+			app.recordBuild(start, err)
+			if err != nil {
 				fmt.Printf(">> Build failed: %v\n", err)
+				state(MessageFailed, fmt.Errorf("build failed: %w", err))
 				continue // Don't run if build fails
 			}
 		}
 
-		fmt.Println(">> Running...")
-		p, err := app.Runner.Start(app.RunCmd)
-		if err != nil {
-			fmt.Printf(">> Run failed: %v\n", err)
+		if app.RunCmd == "" && len(app.RunArgs) == 0 {
+			// Only Static serves the pages; they can be reloaded
+			// right away.
+			state(MessageReady, nil)
+			app.broadcastReload(Message{Type: MessageReload, BuildID: buildID, Changed: changed, DurationMS: time.Since(since).Milliseconds()})
 			continue
 		}
-		currentProcess = p
 
-		// Wait for the server to be ready
-		if err := app.waitForHealth(); err != nil {
-			fmt.Printf(">> Health check failed: %v\n", err)
+		state(MessageStarting, nil)
+		p, err := app.launch()
+		if err != nil {
+			fmt.Printf(">> Run failed: %v\n", err)
+			state(MessageFailed, fmt.Errorf("run failed: %w", err))
 			continue
 		}
 
-		// Notify clients to reload after the server has restarted
-		app.Hub.broadcast <- []byte("reload")
+		// Wait for the server to be ready, then notify clients to reload
+		p, msg := app.awaitReady(p)
+		currentProcess = p
+		app.recordProcess(p)
+		switch {
+		case p == nil:
+			state(MessageFailed, errors.New("run failed"))
+		case msg == nil:
+			state(MessageFailed, errors.New("health check failed"))
+		case msg.Type == MessageReload:
+			// Pages the reload skips must not keep showing "starting".
+			state(MessageReady, nil)
+		}
+		if msg != nil {
+			msg.BuildID = buildID
+			msg.Changed = changed
+			msg.DurationMS = time.Since(since).Milliseconds()
+			app.broadcastReload(*msg)
+		}
 
 		// Wait for process in a goroutine so we don't block the loop
 		// We don't necessarily need to Wait() here for the loop logic since we handle headers in the restart
 		// but standard practice to avoid zombies happens in restart logic via Wait()
 	}
-	return nil
+}
+
+// broadcastReload sends m to the browsers its changes affect, according to
+// Routes.
+func (app *Livereload) broadcastReload(m Message) {
+	app.mu.RLock()
+	routes := app.Routes
+	app.mu.RUnlock()
+	app.Hub.broadcastChange(m, routes)
 }
 
 func AddRecursiveWatch(watcher FileWatcher, paths []string, ignoreMap map[string]bool) error {
@@ -266,3 +628,27 @@ func AddRecursiveWatch(watcher FileWatcher, paths []string, ignoreMap map[string
 	}
 	return nil
 }
+
+// RemoveRecursiveWatch stops watching the directories under paths. Paths
+// that aren't watched are skipped.
+func RemoveRecursiveWatch(watcher FileWatcher, paths []string) error {
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		err := filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil // removed since it was watched
+				}
+				return err
+			}
+			if info.IsDir() {
+				watcher.Remove(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package livereload
 
 import (
+	"encoding/json"
+	"errors"
 	"io"
 	"log"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -23,8 +29,9 @@ func NewMockWatcher() *MockWatcher {
 	}
 }
 
-func (m *MockWatcher) Add(name string) error { return nil }
-func (m *MockWatcher) Close() error          { return nil }
+func (m *MockWatcher) Add(name string) error    { return nil }
+func (m *MockWatcher) Remove(name string) error { return nil }
+func (m *MockWatcher) Close() error             { return nil }
 func (m *MockWatcher) Events() chan fsnotify.Event {
 	return m.events
 }
@@ -32,21 +39,51 @@ func (m *MockWatcher) Errors() chan error {
 	return m.errors
 }
 
+// MockCommandRunner records the commands it is asked to run. Livereload.Run
+// calls it from another goroutine, so tests read the histories through Runs,
+// Starts, ArgvRuns and ArgvStarts, and change RunError with SetRunError, once
+// Run has started.
 type MockCommandRunner struct {
-	RunHistory   []string
-	StartHistory []string
-	RunError     error
-	StartError   error
-	MockProcess  *MockProcess
+	RunHistory       []string
+	StartHistory     []string
+	RunArgsHistory   [][]string
+	StartArgsHistory [][]string
+	RunError         error
+	StartError       error
+	MockProcess      *MockProcess
+
+	mu sync.Mutex
 }
 
 func (m *MockCommandRunner) Run(cmd string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.RunHistory = append(m.RunHistory, cmd)
 	return m.RunError
 }
 
 func (m *MockCommandRunner) Start(cmd string) (Process, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.StartHistory = append(m.StartHistory, cmd)
+	return m.process()
+}
+
+func (m *MockCommandRunner) RunArgs(argv []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.RunArgsHistory = append(m.RunArgsHistory, argv)
+	return m.RunError
+}
+
+func (m *MockCommandRunner) StartArgs(argv []string) (Process, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.StartArgsHistory = append(m.StartArgsHistory, argv)
+	return m.process()
+}
+
+func (m *MockCommandRunner) process() (Process, error) {
 	if m.StartError != nil {
 		return nil, m.StartError
 	}
@@ -56,21 +93,80 @@ func (m *MockCommandRunner) Start(cmd string) (Process, error) {
 	return m.MockProcess, nil
 }
 
+func (m *MockCommandRunner) Runs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.RunHistory)
+}
+
+func (m *MockCommandRunner) Starts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.StartHistory)
+}
+
+func (m *MockCommandRunner) ArgvRuns() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.RunArgsHistory)
+}
+
+func (m *MockCommandRunner) ArgvStarts() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.StartArgsHistory)
+}
+
+// ClearHistory forgets the commands run so far.
+func (m *MockCommandRunner) ClearHistory() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.RunHistory, m.StartHistory = nil, nil
+	m.RunArgsHistory, m.StartArgsHistory = nil, nil
+}
+
+func (m *MockCommandRunner) SetRunError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.RunError = err
+}
+
+// MockProcess records whether it was killed and waited for. Tests read
+// that through Called once Run has started.
 type MockProcess struct {
 	KillCalled bool
 	WaitCalled bool
+
+	mu sync.Mutex
 }
 
 func (m *MockProcess) Kill() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.KillCalled = true
 	return nil
 }
 
 func (m *MockProcess) Wait() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.WaitCalled = true
 	return nil
 }
 
+// Called reports whether Kill and Wait were called since the last Reset.
+func (m *MockProcess) Called() (kill, wait bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.KillCalled, m.WaitCalled
+}
+
+func (m *MockProcess) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.KillCalled, m.WaitCalled = false, false
+}
+
 // === Tests ===
 
 func TestLivereload_Unit(t *testing.T) {
@@ -102,28 +198,22 @@ func TestLivereload_Unit(t *testing.T) {
 	// We need to wait a small bit for the goroutine to proceed
 	time.Sleep(50 * time.Millisecond)
 
-	if len(mockRunner.StartHistory) != 1 {
-		t.Fatalf("Expected 1 start, got %d", len(mockRunner.StartHistory))
-	}
-	if mockRunner.StartHistory[0] != "./app" {
-		t.Errorf("Expected run command './app', got '%s'", mockRunner.StartHistory[0])
+	starts, runs := mockRunner.Starts(), mockRunner.Runs()
+	if len(starts) != 1 {
+		t.Fatalf("Expected 1 start, got %d", len(starts))
 	}
-	// Initial run checks build command too if buildCmd is present
-	if len(mockRunner.RunHistory) != 0 {
-		// Wait, app.BuildCmd IS set.
-		// Wait, the order: check build first
+	if starts[0] != "./app" {
+		t.Errorf("Expected run command './app', got '%s'", starts[0])
 	}
-
-	if len(mockRunner.RunHistory) != 1 {
-		t.Fatalf("Expected 1 build, got %d", len(mockRunner.RunHistory))
+	if len(runs) != 1 {
+		t.Fatalf("Expected 1 build, got %d", len(runs))
 	}
-	if mockRunner.RunHistory[0] != "go build" {
-		t.Errorf("Expected build command 'go build', got '%s'", mockRunner.RunHistory[0])
+	if runs[0] != "go build" {
+		t.Errorf("Expected build command 'go build', got '%s'", runs[0])
 	}
 
 	// Reset history for next check
-	mockRunner.RunHistory = nil
-	mockRunner.StartHistory = nil
+	mockRunner.ClearHistory()
 
 	// Trigger a file event
 	mockWatcher.events <- fsnotify.Event{Name: "main.go", Op: fsnotify.Write}
@@ -132,19 +222,144 @@ func TestLivereload_Unit(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Should have killed the old process, built, and run again
-	if !mockProcess.KillCalled {
+	killed, waited := mockProcess.Called()
+	if !killed {
 		t.Error("Expected previous process to be killed")
 	}
-	if !mockProcess.WaitCalled {
+	if !waited {
 		t.Error("Expected previous process to be waited on")
 	}
 
-	if len(mockRunner.RunHistory) != 1 {
-		t.Errorf("Expected 1 build after edit, got %d", len(mockRunner.RunHistory))
+	if runs := mockRunner.Runs(); len(runs) != 1 {
+		t.Errorf("Expected 1 build after edit, got %d", len(runs))
 	}
-	if len(mockRunner.StartHistory) != 1 {
-		t.Errorf("Expected 1 run after edit, got %d", len(mockRunner.StartHistory))
+	if starts := mockRunner.Starts(); len(starts) != 1 {
+		t.Errorf("Expected 1 run after edit, got %d", len(starts))
 	}
 
 	// Stop the loop (not implemented in Run() strictly, so we just kill test)
 }
+
+func TestLivereload_Argv(t *testing.T) {
+	mockRunner := &MockCommandRunner{}
+	app := &Livereload{
+		Watcher:      NewMockWatcher(),
+		Runner:       mockRunner,
+		BuildCmd:     "ignored",
+		BuildArgs:    []string{"go", "build", "-o", "my app"},
+		RunArgs:      []string{"./my app", "--port", "8080"},
+		IgnoreMap:    make(map[string]bool),
+		DebounceTime: 10 * time.Millisecond,
+		RestartDelay: 10 * time.Millisecond,
+		Log:          log.New(io.Discard, "", 0),
+		Hub:          NewReloadHub(),
+	}
+	go app.Run()
+	time.Sleep(50 * time.Millisecond)
+
+	if runs, starts := mockRunner.Runs(), mockRunner.Starts(); len(runs) != 0 || len(starts) != 0 {
+		t.Errorf("Expected no shell commands, got builds %v and runs %v", runs, starts)
+	}
+	if runs := mockRunner.ArgvRuns(); len(runs) != 1 || runs[0][3] != "my app" {
+		t.Errorf("Expected argv build, got %v", runs)
+	}
+	if starts := mockRunner.ArgvStarts(); len(starts) != 1 || starts[0][0] != "./my app" {
+		t.Errorf("Expected argv run, got %v", starts)
+	}
+}
+
+func TestLivereload_Reconfigure(t *testing.T) {
+	mockWatcher := NewMockWatcher()
+	mockRunner := &MockCommandRunner{}
+	configChanged := make(chan bool, 1)
+	app := &Livereload{
+		Watcher:        mockWatcher,
+		Runner:         mockRunner,
+		RunCmd:         "./app",
+		IgnoreMap:      make(map[string]bool),
+		DebounceTime:   10 * time.Millisecond,
+		Log:            log.New(io.Discard, "", 0),
+		Hub:            NewReloadHub(),
+		ConfigFile:     "livereload.toml",
+		OnConfigChange: func() { configChanged <- true },
+	}
+	go app.Run()
+	time.Sleep(50 * time.Millisecond)
+
+	// A change to the config file is handed to OnConfigChange instead of
+	// restarting the process.
+	mockWatcher.events <- fsnotify.Event{Name: "./livereload.toml", Op: fsnotify.Write}
+	select {
+	case <-configChanged:
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnConfigChange to be called")
+	}
+	if starts := mockRunner.Starts(); len(starts) != 1 {
+		t.Errorf("Expected no restart for config change, got %v", starts)
+	}
+
+	app.Reconfigure(func(app *Livereload) {
+		app.RunCmd = "./app --new"
+		app.IgnoreMap = map[string]bool{"main.go": true}
+	})
+	time.Sleep(50 * time.Millisecond)
+	if starts := mockRunner.Starts(); len(starts) != 2 || starts[1] != "./app --new" {
+		t.Errorf("Expected restart with new command, got %v", starts)
+	}
+
+	// The new ignore rules apply to subsequent events.
+	mockWatcher.events <- fsnotify.Event{Name: "main.go", Op: fsnotify.Write}
+	time.Sleep(50 * time.Millisecond)
+	if starts := mockRunner.Starts(); len(starts) != 2 {
+		t.Errorf("Expected ignored file not to restart, got %v", starts)
+	}
+}
+
+func TestLivereload_Lifecycle(t *testing.T) {
+	mockWatcher := NewMockWatcher()
+	mockRunner := &MockCommandRunner{}
+	app := &Livereload{
+		Watcher:      mockWatcher,
+		Runner:       mockRunner,
+		BuildCmd:     "go build",
+		RunCmd:       "./app",
+		IgnoreMap:    make(map[string]bool),
+		DebounceTime: 10 * time.Millisecond,
+		RestartDelay: 10 * time.Millisecond,
+		Log:          log.New(io.Discard, "", 0),
+		Hub:          NewReloadHub(),
+	}
+	go app.Run()
+	time.Sleep(50 * time.Millisecond)
+
+	page := newSSEClient(httptest.NewRequest("GET", "/events?v=1", nil))
+	app.Hub.register <- page
+	types := func() []MessageType {
+		var got []MessageType
+		for {
+			select {
+			case data := <-page.ch:
+				var m Message
+				if err := json.Unmarshal(data, &m); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, m.Type)
+			case <-time.After(100 * time.Millisecond):
+				return got
+			}
+		}
+	}
+
+	mockWatcher.events <- fsnotify.Event{Name: "main.go", Op: fsnotify.Write}
+	want := []MessageType{MessageBuilding, MessageStarting, MessageReady, MessageReload}
+	if got := types(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	mockRunner.SetRunError(errors.New("exit status 1"))
+	mockWatcher.events <- fsnotify.Event{Name: "main.go", Op: fsnotify.Write}
+	want = []MessageType{MessageBuilding, MessageFailed}
+	if got := types(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
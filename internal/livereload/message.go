@@ -0,0 +1,126 @@
+package livereload
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MessageVersion is the version of the Message envelope. Browsers ask for
+// it with the v query parameter when connecting, as in /ws?v=1; those that
+// don't get the plain-text messages of version 0, "reload" and
+// "error:<text>".
+const MessageVersion = 1
+
+// MessageType says what a Message is about.
+type MessageType string
+
+const (
+	MessageReload MessageType = "reload" // reload the page
+	MessageError  MessageType = "error"  // show Error over the page
+	MessageLog    MessageType = "log"    // a BrowserLog, sent by the page
+
+	// The states of a build and restart, for the page to show. Only the
+	// JSON envelope has them.
+	MessageBuilding MessageType = "building" // the build command is running
+	MessageStarting MessageType = "starting" // the app is starting
+	MessageReady    MessageType = "ready"    // the app passed its health check
+	MessageFailed   MessageType = "failed"   // Error says what failed
+)
+
+// Message is what the hub sends to the browsers, encoded as JSON.
+type Message struct {
+	V          int         `json:"v"`
+	Type       MessageType `json:"type"`
+	BuildID    int64       `json:"build_id,omitempty"`    // counts the builds and restarts of the session
+	Changed    []string    `json:"changed,omitempty"`     // files that changed since the previous build
+	DurationMS int64       `json:"duration_ms,omitempty"` // from the change to the message
+	Error      string      `json:"error,omitempty"`
+}
+
+// BrowserLog is an error a page forwards over its WebSocket when the
+// script tag has data-forward-console.
+type BrowserLog struct {
+	Type    MessageType `json:"type"` // MessageLog
+	Kind    string      `json:"kind"` // "console.error", "exception" or "rejection"
+	Message string      `json:"message"`
+	Stack   string      `json:"stack,omitempty"`
+	Page    string      `json:"page"` // URL of the page
+}
+
+// encode returns m as sent to a browser that speaks version, or nil if such
+// a browser has no use for it.
+func (m Message) encode(version int) []byte {
+	if version == 0 {
+		switch m.Type {
+		case MessageReload:
+			return []byte("reload")
+		case MessageError:
+			return []byte("error:" + m.Error)
+		}
+		return nil
+	}
+	m.V = MessageVersion
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// messageVersion returns the message version asked for by r, capped at the
+// latest one.
+func messageVersion(r *http.Request) int {
+	v, err := strconv.Atoi(r.URL.Query().Get("v"))
+	if err != nil || v < 0 {
+		return 0
+	}
+	return min(v, MessageVersion)
+}
+
+// changeSet collects the files changed since the Run loop last took them,
+// and when the first of them changed.
+type changeSet struct {
+	mu    sync.Mutex
+	paths []string
+	since time.Time
+}
+
+// add records a change to path, made relative to the working directory
+// if it is inside it.
+func (c *changeSet) add(path string) {
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil && filepath.IsLocal(rel) {
+				path = rel
+			}
+		}
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.since.IsZero() {
+		c.since = time.Now()
+	}
+	if !slices.Contains(c.paths, path) {
+		c.paths = append(c.paths, path)
+	}
+}
+
+// take returns the changes and starts a new set. since is the current
+// time if nothing changed.
+func (c *changeSet) take() (paths []string, since time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paths, since = c.paths, c.since
+	c.paths, c.since = nil, time.Time{}
+	if since.IsZero() {
+		since = time.Now()
+	}
+	return paths, since
+}
@@ -0,0 +1,126 @@
+package livereload
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// LocalHosts are the hosts pages may be served from when AllowedOrigins is
+// empty.
+var LocalHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// originAllowed reports whether a browser on the page that sent r may use
+// the socket, the event stream and the API. Requests without an Origin
+// header don't come from a page and are allowed, as are pages served by
+// the livereload server itself.
+func (app *Livereload) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	app.mu.RLock()
+	allowed := app.AllowedOrigins
+	app.mu.RUnlock()
+	if len(allowed) == 0 {
+		allowed = LocalHosts
+	}
+	if OriginMatches(allowed, origin) || app.isOwnOrigin(origin) {
+		return true
+	}
+	if app.Log != nil {
+		app.Log.Printf("Rejected a request for %s from %s; add it to allowed_origins to allow it", r.URL.Path, origin)
+	}
+	return false
+}
+
+// isOwnOrigin reports whether origin is that of the livereload server: a
+// page on ReloadPort of ReloadHost or of one of LocalHosts. A server
+// listening on every interface may also be reached by IP address.
+func (app *Livereload) isOwnOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme != app.Scheme() {
+		return false
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	if port != strconv.Itoa(app.ReloadPort) {
+		return false
+	}
+	host := u.Hostname()
+	if slices.ContainsFunc(LocalHosts, func(h string) bool { return strings.EqualFold(h, host) }) || strings.EqualFold(host, strings.Trim(app.ReloadHost, "[]")) {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(app.ReloadHost, "[]"))
+	return (app.ReloadHost == "" || ip != nil && ip.IsUnspecified()) && net.ParseIP(host) != nil
+}
+
+// hostAllowed reports whether the Host header of r names the livereload
+// server. A page that a DNS rebinding attack points at the server sends
+// the attacker's host name, which must be rejected even though the page
+// looks same-origin to the browser and may send no Origin header. IP
+// addresses, localhost, ReloadHost and the hosts in AllowedOrigins are
+// accepted.
+func (app *Livereload) hostAllowed(r *http.Request) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "" || net.ParseIP(host) != nil || strings.EqualFold(host, "localhost") || strings.EqualFold(host, strings.Trim(app.ReloadHost, "[]")) {
+		return true
+	}
+	app.mu.RLock()
+	allowed := app.AllowedOrigins
+	app.mu.RUnlock()
+	for _, a := range allowed {
+		if a == "*" {
+			return true
+		}
+		if u, err := url.Parse(a); err == nil && strings.Contains(a, "://") {
+			a = u.Hostname()
+		}
+		if strings.EqualFold(strings.Trim(a, "[]"), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost rejects requests that fail hostAllowed.
+func (app *Livereload) checkHost(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.hostAllowed(r) {
+			if app.Log != nil {
+				app.Log.Printf("Rejected a request for %s to host %s; add it to allowed_origins to allow it", r.URL.Path, r.Host)
+			}
+			http.Error(w, "host not allowed", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// OriginMatches reports whether origin matches one of allowed. An entry is
+// either an origin such as "https://app.test:8443", which must match
+// exactly, a host such as "localhost", which matches that host on any
+// scheme and port, or "*", which matches everything.
+func OriginMatches(allowed []string, origin string) bool {
+	u, err := url.Parse(origin)
+	for _, a := range allowed {
+		switch {
+		case a == "*", strings.EqualFold(strings.TrimSuffix(a, "/"), origin):
+			return true
+		case !strings.Contains(a, "://") && err == nil && u.Hostname() != "":
+			if strings.EqualFold(strings.Trim(a, "[]"), u.Hostname()) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package livereload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestOriginMatches(t *testing.T) {
+	allowed := []string{"localhost", "::1", "https://app.test:8443", "null"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"http://localhost:3000", true},
+		{"https://LOCALHOST", true},
+		{"http://[::1]:8080", true},
+		{"https://app.test:8443", true},
+		{"null", true},
+		{"http://app.test:8443", false},
+		{"https://app.test", false},
+		{"http://localhost.evil.test", false},
+		{"http://evil.test", false},
+	}
+	for _, tt := range tests {
+		if got := OriginMatches(allowed, tt.origin); got != tt.want {
+			t.Errorf("OriginMatches(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	if !OriginMatches([]string{"*"}, "http://evil.test") {
+		t.Error("Expected * to match any origin")
+	}
+}
+
+func TestOrigins(t *testing.T) {
+	app := &Livereload{Hub: NewReloadHub(), APIToken: "secret", AllowedOrigins: []string{"http://localhost:3000"}}
+	go app.Hub.Run()
+	srv := httptest.NewServer(app.handler())
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	app.ReloadHost = u.Hostname()
+	app.ReloadPort, _ = strconv.Atoi(u.Port())
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+	evil := "evil.test:" + u.Port()
+
+	tests := []struct {
+		origin string
+		host   string // Host header, if not the server's address
+		want   bool
+	}{
+		{origin: "", want: true}, // not a browser
+		{origin: "http://localhost:3000", want: true},
+		{origin: srv.URL, want: true}, // served by livereload itself
+		{origin: "http://localhost:" + u.Port(), host: "localhost:" + u.Port(), want: true}, // likewise
+		{origin: "http://localhost:4000", want: false},
+		{origin: "https://evil.test", want: false},
+		// DNS rebinding: evil.test resolves to the server, so the page
+		// looks same-origin to the browser.
+		{origin: "http://" + evil, host: evil, want: false},
+		{origin: "", host: evil, want: false},
+	}
+	for _, tt := range tests {
+		name := tt.origin
+		if tt.host != "" {
+			name += " to " + tt.host
+		}
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		if tt.host != "" {
+			header.Set("Host", tt.host)
+		}
+
+		ws, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if got := err == nil; got != tt.want {
+			t.Errorf("WebSocket from %q: expected accepted %v, got error %v", name, tt.want, err)
+		}
+		if err == nil {
+			ws.Close()
+		} else if resp != nil && resp.StatusCode != http.StatusForbidden {
+			t.Errorf("WebSocket from %q: expected 403, got %d", name, resp.StatusCode)
+		}
+
+		req, _ := http.NewRequest("GET", srv.URL+"/events", nil)
+		req.Header = header.Clone()
+		req.Host = tt.host
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.StatusCode == http.StatusOK; got != tt.want {
+			t.Errorf("EventSource from %q: expected accepted %v, got %d", name, tt.want, resp.StatusCode)
+		}
+		if acao := resp.Header.Get("Access-Control-Allow-Origin"); tt.want && acao != tt.origin {
+			t.Errorf("EventSource from %q: expected Access-Control-Allow-Origin %q, got %q", name, tt.origin, acao)
+		}
+
+		req, _ = http.NewRequest("POST", srv.URL+"/api/reload", nil)
+		req.Header = header.Clone()
+		req.Host = tt.host
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.StatusCode == http.StatusAccepted; got != tt.want {
+			t.Errorf("API from %q: expected accepted %v, got %d", name, tt.want, resp.StatusCode)
+		}
+	}
+}
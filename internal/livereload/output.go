@@ -0,0 +1,248 @@
+package livereload
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+	ansiBold  = "\x1b[1m"
+)
+
+// labelColors assigns a color to the labels used by RealCommandRunner.
+// Unknown labels are printed bold.
+var labelColors = map[string]string{
+	"build": ansiCyan,
+	"run":   ansiGreen,
+}
+
+// Output attributes child process output. Every line written through a
+// writer returned by Writer is prefixed with its label, colored if Color is
+// set, and written to W. If Tee is set, each line is also written there,
+// uncolored and timestamped. OnLine, if set, is called with every line,
+// e.g. to feed a LogProbe. With neither Prefix nor Tee, the output is
+// written to W unchanged.
+type Output struct {
+	W      io.Writer
+	Prefix bool
+	Color  bool
+	Tee    io.Writer
+	OnLine func(label string, line []byte)
+
+	mu sync.Mutex // serializes lines so streams never interleave mid-line
+}
+
+// Writer returns a line-buffered writer for one stream of a process.
+// Lines from stderr are marked with "!" instead of "|" and, when colored,
+// printed in red. Call Flush on the returned writer once the process has
+// exited to emit a trailing line without a newline.
+func (o *Output) Writer(label string, stderr bool) *LineWriter {
+	if !o.Prefix && o.Tee == nil {
+		// Nothing is added to the lines, so prompts and progress bars
+		// are written as they come; lines are only split for OnLine.
+		return &LineWriter{
+			raw: func(p []byte) {
+				o.mu.Lock()
+				defer o.mu.Unlock()
+				o.W.Write(p)
+			},
+			fn: func(line []byte) {
+				if o.OnLine != nil {
+					o.OnLine(label, line)
+				}
+			},
+		}
+	}
+	return &LineWriter{fn: func(line []byte) {
+		o.writeLine(label, stderr, line)
+	}}
+}
+
+func (o *Output) writeLine(label string, stderr bool, line []byte) {
+	if o.OnLine != nil {
+		o.OnLine(label, line)
+	}
+
+	sep := "|"
+	if stderr {
+		sep = "!"
+	}
+
+	var b bytes.Buffer
+	if o.Prefix {
+		if o.Color {
+			color, ok := labelColors[label]
+			if !ok {
+				color = ansiBold
+			}
+			if stderr {
+				color = ansiRed
+			}
+			fmt.Fprintf(&b, "%s%-5s %s%s ", color, label, sep, ansiReset)
+		} else {
+			fmt.Fprintf(&b, "%-5s %s ", label, sep)
+		}
+	}
+	b.Write(line)
+	b.WriteByte('\n')
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.W.Write(b.Bytes())
+	if o.Tee != nil {
+		fmt.Fprintf(o.Tee, "%s %-5s %s %s\n", time.Now().Format(time.RFC3339), label, sep, line)
+	}
+}
+
+const (
+	// partialLineDelay is how long a partial line, such as a prompt, is
+	// held back waiting for the rest of it.
+	partialLineDelay = 100 * time.Millisecond
+	// maxLineLength is the longest line held back; longer ones are cut.
+	maxLineLength = 64 << 10
+)
+
+// LineWriter buffers writes and passes each complete line, without its
+// trailing newline, to a callback. A partial line is passed on once no
+// more output arrives for partialLineDelay or it grows to maxLineLength.
+// If raw is set, every write is also passed to it unchanged.
+type LineWriter struct {
+	mu    sync.Mutex
+	buf   []byte
+	fn    func(line []byte)
+	raw   func(p []byte)
+	timer *time.Timer
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	if w.raw != nil {
+		w.raw(p)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(bytes.TrimSuffix(w.buf[:i], []byte("\r")))
+		w.buf = w.buf[i+1:]
+	}
+	for len(w.buf) >= maxLineLength {
+		w.fn(w.buf[:maxLineLength])
+		w.buf = w.buf[maxLineLength:]
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if len(w.buf) > 0 {
+		w.timer = time.AfterFunc(partialLineDelay, w.Flush)
+	}
+	return len(p), nil
+}
+
+// Flush passes any buffered partial line to the callback.
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if len(w.buf) > 0 {
+		w.fn(w.buf)
+		w.buf = nil
+	}
+}
+
+// RotatingFile is an io.Writer appending to Path. Once the file would grow
+// beyond MaxSize bytes it is renamed to Path.1 (shifting older files up to
+// Path.MaxBackups) and a new file is started.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+	if r.MaxBackups > 0 {
+		for i := r.MaxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.Path, i), fmt.Sprintf("%s.%d", r.Path, i+1))
+		}
+		if err := os.Rename(r.Path, r.Path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.Path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// IsTerminal reports whether f is a character device, i.e. output written
+// to it is likely shown in a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
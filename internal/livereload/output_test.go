@@ -0,0 +1,116 @@
+package livereload
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutput_PrefixesLines(t *testing.T) {
+	var out, tee bytes.Buffer
+	o := &Output{W: &out, Prefix: true, Tee: &tee}
+
+	stdout := o.Writer("run", false)
+	stderr := o.Writer("run", true)
+	fmt.Fprint(stdout, "hello ")
+	fmt.Fprint(stderr, "oops\n")
+	fmt.Fprint(stdout, "world\r\npartial")
+	if strings.Contains(out.String(), "partial") {
+		t.Errorf("Partial line written before Flush: %q", out.String())
+	}
+	stdout.Flush()
+
+	want := "run   ! oops\nrun   | hello world\nrun   | partial\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+	if n := strings.Count(tee.String(), "\n"); n != 3 {
+		t.Errorf("Expected 3 lines in tee, got %d: %q", n, tee.String())
+	}
+}
+
+func TestOutput_PartialLines(t *testing.T) {
+	var out bytes.Buffer
+	o := &Output{W: &out, Prefix: true}
+	written := func() string {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return out.String()
+	}
+
+	// A prompt without a newline is shown once the process pauses.
+	w := o.Writer("run", false)
+	fmt.Fprint(w, "Password: ")
+	time.Sleep(2 * partialLineDelay)
+	if got, want := written(), "run   | Password: \n"; got != want {
+		t.Errorf("Expected %q after a pause, got %q", want, got)
+	}
+
+	// Output that never ends its line is cut instead of piling up.
+	out.Reset()
+	w.Write(bytes.Repeat([]byte("."), maxLineLength+1))
+	if n := strings.Count(written(), "\n"); n != 1 {
+		t.Errorf("Expected a line once the buffer is full, got %d", n)
+	}
+	w.Flush()
+
+	// Without a prefix or a log file, output is passed through as is,
+	// while OnLine still gets whole lines.
+	var lines []string
+	out.Reset()
+	o = &Output{W: &out, OnLine: func(label string, line []byte) { lines = append(lines, string(line)) }}
+	w = o.Writer("build", false)
+	fmt.Fprint(w, "10%\r50%\r")
+	if got := written(); got != "10%\r50%\r" {
+		t.Errorf("Expected output passed through, got %q", got)
+	}
+	fmt.Fprint(w, "100%\ndone\n")
+	if want := []string{"10%\r50%\r100%", "done"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("Expected lines %q, got %q", want, lines)
+	}
+}
+
+func TestOutput_Color(t *testing.T) {
+	var out bytes.Buffer
+	o := &Output{W: &out, Prefix: true, Color: true}
+	w := o.Writer("build", true)
+	fmt.Fprintln(w, "failed")
+
+	if !strings.HasPrefix(out.String(), ansiRed) || !strings.Contains(out.String(), ansiReset+" failed") {
+		t.Errorf("Expected red stderr prefix, got %q", out.String())
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r := &RotatingFile{Path: path, MaxSize: 10, MaxBackups: 2}
+	defer r.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(name), want, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 2 backups, stat err %v", err)
+	}
+}
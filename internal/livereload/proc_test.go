@@ -0,0 +1,31 @@
+package livereload
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRealCommandRunner_KillsForkedChildren(t *testing.T) {
+	// The shell forks sleep instead of exec'ing it, and sleep holds the
+	// output pipe open for as long as it runs, so Wait only returns early
+	// if sleep was killed too.
+	started := make(chan bool, 1)
+	r := &RealCommandRunner{Output: &Output{W: io.Discard, OnLine: func(label string, line []byte) {
+		started <- true
+	}}}
+	p, err := r.Start("sleep 30 & echo started; wait")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	<-started
+
+	start := time.Now()
+	if err := p.Kill(); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	p.Wait()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected Wait to return once killed, took %v", d)
+	}
+}
@@ -0,0 +1,154 @@
+package livereload
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Route maps the files matched by Files to the pages matched by Pages, so
+// that changing them only reloads those pages. Patterns are slash-separated
+// as in path.Match, and ** also matches any number of directories. Files
+// are matched against the changed paths, relative ones being relative to
+// the working directory, Pages against the path of the page's URL.
+type Route struct {
+	Files []string
+	Pages []string
+}
+
+// MatchPath reports whether name matches the Route pattern. The only
+// possible error is path.ErrBadPattern.
+func MatchPath(pattern, name string) (bool, error) {
+	segments := strings.Split(pattern, "/")
+	for _, s := range segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return false, err
+		}
+	}
+	return matchSegments(segments, strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try matching the rest at every remaining depth.
+			for i := 0; i <= len(name); i++ {
+				if ok, err := matchSegments(pattern[1:], name[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		if ok, err := path.Match(pattern[0], name[0]); !ok || err != nil {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// matchAny reports whether name matches one of patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := MatchPath(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyFile reports whether the changed file matches one of the Files
+// patterns. Both are made absolute first: patterns from a config file are
+// resolved against its directory, while changed paths are relative to the
+// working directory when they are inside it.
+func matchAnyFile(patterns []string, file string) bool {
+	file = absSlash(file)
+	for _, p := range patterns {
+		if ok, _ := MatchPath(absSlash(p), file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// absSlash returns the absolute, slash-separated form of the path p.
+func absSlash(p string) string {
+	if abs, err := filepath.Abs(filepath.FromSlash(p)); err == nil {
+		p = abs
+	}
+	return filepath.ToSlash(p)
+}
+
+// registration returns the page a browser registered when connecting, as
+// in /ws?page=https://app.test/admin/&deps=templates/admin.html, and the
+// files it depends on.
+func registration(r *http.Request) (page string, deps []string) {
+	q := r.URL.Query()
+	if u, err := url.Parse(q.Get("page")); err == nil {
+		page = u.Path
+	}
+	for _, d := range strings.Split(q.Get("deps"), ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			deps = append(deps, d)
+		}
+	}
+	return page, deps
+}
+
+// dependsOn reports whether a client with deps depends on file. A
+// dependency matches the whole path or its end, so "static/app.css" matches
+// "web/static/app.css".
+func dependsOn(deps []string, file string) bool {
+	for _, d := range deps {
+		if ok, _ := MatchPath(d, file); ok {
+			return true
+		}
+		if ok, _ := MatchPath("**/"+d, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// affected returns the clients to reload for changes to files: those whose
+// page a route maps the files to and those that depend on them. A file
+// that neither a route nor a client claims could affect any page, so it
+// reloads them all, as do clients that registered neither a page nor
+// dependencies.
+func affected(clients map[client]bool, routes []Route, files []string) map[client]bool {
+	to := make(map[client]bool)
+	for c := range clients {
+		if page, deps := c.target(); page == "" && len(deps) == 0 {
+			to[c] = true
+		}
+	}
+	for _, f := range files {
+		known := false
+		for c := range clients {
+			if _, deps := c.target(); dependsOn(deps, f) {
+				to[c] = true
+				known = true
+			}
+		}
+		for _, r := range routes {
+			if !matchAnyFile(r.Files, f) {
+				continue
+			}
+			known = true
+			for c := range clients {
+				if page, _ := c.target(); page != "" && matchAny(r.Pages, page) {
+					to[c] = true
+				}
+			}
+		}
+		if !known {
+			return clients
+		}
+	}
+	return to
+}
@@ -0,0 +1,99 @@
+package livereload
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"templates/*.html", "templates/admin.html", true},
+		{"templates/*.html", "templates/admin/users.html", false},
+		{"templates/**", "templates/admin/users.html", true},
+		{"templates/**/*.html", "templates/index.html", true},
+		{"**/*.css", "static/css/site.css", true},
+		{"/admin/**", "/admin/", true},
+		{"/admin/**", "/admin", true},
+		{"/admin/**", "/administrator", false},
+		{"/docs/*", "/docs/intro", true},
+	}
+	for _, tt := range tests {
+		got, err := MatchPath(tt.pattern, tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, %v; want %v", tt.pattern, tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := MatchPath("templates/[", "static/x"); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+}
+
+func TestAffected(t *testing.T) {
+	newClient := func(page, deps string) client {
+		q := url.Values{"page": {page}, "deps": {deps}}
+		return newSSEClient(httptest.NewRequest("GET", "/events?"+q.Encode(), nil))
+	}
+	admin := newClient("http://localhost:8080/admin/users?id=1", "")
+	site := newClient("http://localhost:8080/blog/", "")
+	docs := newClient("http://localhost:3000/intro.html", "docs/intro.md, static/docs.css")
+	old := newClient("", "")
+	clients := map[client]bool{admin: true, site: true, docs: true, old: true}
+	routes := []Route{
+		{Files: []string{"templates/admin/**"}, Pages: []string{"/admin/**"}},
+		{Files: []string{"templates/**", "static/**"}, Pages: []string{"/", "/blog/**"}},
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []client
+	}{
+		{"admin template", []string{"templates/admin/users.html"}, []client{admin, site, old}},
+		{"site stylesheet", []string{"static/site.css"}, []client{site, old}},
+		{"dependency", []string{"docs/intro.md"}, []client{docs, old}},
+		{"dependency by suffix", []string{"web/static/docs.css"}, []client{docs, old}},
+		{"unknown file", []string{"static/site.css", "main.go"}, []client{admin, site, docs, old}},
+	}
+	for _, tt := range tests {
+		got := affected(clients, routes, tt.files)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %d clients, got %d", tt.name, len(tt.want), len(got))
+		}
+		for _, c := range tt.want {
+			if !got[c] {
+				page, _ := c.target()
+				t.Errorf("%s: expected the page %q to be reloaded", tt.name, page)
+			}
+		}
+	}
+}
+
+func TestAffected_ConfigElsewhere(t *testing.T) {
+	// Patterns from a config file given by absolute path are resolved
+	// against its directory, which need not be the working directory.
+	root := t.TempDir()
+	configDir := filepath.Join(root, "site")
+	if err := os.MkdirAll(filepath.Join(configDir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	routes := []Route{{Files: []string{filepath.ToSlash(filepath.Join(configDir, "templates/**"))}, Pages: []string{"/blog/**"}}}
+	blog := newSSEClient(httptest.NewRequest("GET", "/events?page=/blog/", nil))
+	admin := newSSEClient(httptest.NewRequest("GET", "/events?page=/admin/", nil))
+	clients := map[client]bool{blog: true, admin: true}
+
+	for _, wd := range []string{configDir, root, filepath.Join(configDir, "templates")} {
+		t.Chdir(wd)
+		var changes changeSet
+		changes.add(filepath.Join(configDir, "templates", "post.html"))
+		files, _ := changes.take()
+		if got := affected(clients, routes, files); !got[blog] || got[admin] {
+			t.Errorf("In %s: expected %v to only reload the blog, got %d pages", wd, files, len(got))
+		}
+	}
+}
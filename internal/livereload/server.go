@@ -1,34 +1,169 @@
 package livereload
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+	"unicode"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for local development
-	},
+// client is a browser connected to the hub. send must not block: it only
+// queues the message for the client's own writer. version is the
+// MessageVersion the browser asked for and target the page and
+// dependencies it registered.
+type client interface {
+	send(message []byte) error
+	close()
+	version() int
+	target() (page string, deps []string)
 }
 
+const (
+	// sendQueueSize is how many messages a client may fall behind before
+	// it is dropped.
+	sendQueueSize = 16
+	// maxMessageSize limits what browsers may send over the socket.
+	maxMessageSize = 64 << 10
+)
+
+var (
+	// writeWait is how long a single write to a browser may take.
+	writeWait = 10 * time.Second
+	// pongWait is how long a socket may stay silent, pongs included,
+	// before it is considered dead. Pings are sent well within it.
+	pongWait = 60 * time.Second
+)
+
+// queue holds the messages a client's writer has yet to write, encoded
+// for message version v. It is closed by closing done.
+type queue struct {
+	ch        chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	v         int
+	page      string
+	deps      []string
+}
+
+// newQueue returns the queue for a browser connecting with r.
+func newQueue(r *http.Request) *queue {
+	q := &queue{ch: make(chan []byte, sendQueueSize), done: make(chan struct{}), v: messageVersion(r)}
+	q.page, q.deps = registration(r)
+	return q
+}
+
+func (q *queue) version() int {
+	return q.v
+}
+
+func (q *queue) target() (string, []string) {
+	return q.page, q.deps
+}
+
+func (q *queue) send(message []byte) error {
+	select {
+	case <-q.done:
+		return errors.New("client closed")
+	default:
+	}
+	select {
+	case q.ch <- message:
+		return nil
+	default:
+		return errors.New("client is not keeping up")
+	}
+}
+
+func (q *queue) close() {
+	q.closeOnce.Do(func() { close(q.done) })
+}
+
+// wsClient is a browser connected over a WebSocket. writePump writes its
+// queue to the connection.
+type wsClient struct {
+	*queue
+	conn *websocket.Conn
+}
+
+// writePump writes queued messages and pings until the client is closed
+// or a write fails, then closes the connection, which ends the reader too.
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(pongWait * 9 / 10)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case message := <-c.ch:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readPump passes the messages read from the connection to handle until
+// reading fails, which is also how a peer that stopped answering pings is
+// noticed.
+func (c *wsClient) readPump(handle func(message []byte)) {
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		handle(message)
+	}
+}
+
+// sseClient is a browser connected with Server-Sent Events. Its handler
+// writes the queued messages until the client is closed.
+type sseClient struct {
+	*queue
+}
+
+func newSSEClient(r *http.Request) *sseClient {
+	return &sseClient{queue: newQueue(r)}
+}
+
+// ReloadHub fans messages out to the connected browsers. It never waits
+// for a browser: each has its own queue and writer, and those that fall
+// behind are dropped.
 type ReloadHub struct {
-	clients    map[*websocket.Conn]bool
-	broadcast  chan []byte
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
+	clients    map[client]bool
+	broadcast  chan outgoing
+	register   chan client
+	unregister chan client
 	mu         sync.Mutex
 }
 
 func NewReloadHub() *ReloadHub {
 	return &ReloadHub{
-		clients:    make(map[*websocket.Conn]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *websocket.Conn),
-		unregister: make(chan *websocket.Conn),
+		clients:    make(map[client]bool),
+		broadcast:  make(chan outgoing, sendQueueSize),
+		register:   make(chan client),
+		unregister: make(chan client),
 	}
 }
 
@@ -43,15 +178,30 @@ func (h *ReloadHub) Run() {
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				client.Close()
+				client.close()
 			}
 			h.mu.Unlock()
-		case message := <-h.broadcast:
+		case out := <-h.broadcast:
+			m := out.m
+			// Encode m once for each version in use.
+			encoded := make(map[int][]byte)
 			h.mu.Lock()
-			for client := range h.clients {
-				err := client.WriteMessage(websocket.TextMessage, message)
-				if err != nil {
-					client.Close()
+			to := h.clients
+			if m.Type == MessageReload && len(m.Changed) > 0 {
+				to = affected(h.clients, out.routes, m.Changed)
+			}
+			for client := range to {
+				v := client.version()
+				message, ok := encoded[v]
+				if !ok {
+					message = m.encode(v)
+					encoded[v] = message
+				}
+				if message == nil {
+					continue
+				}
+				if err := client.send(message); err != nil {
+					client.close()
 					delete(h.clients, client)
 				}
 			}
@@ -60,12 +210,31 @@ func (h *ReloadHub) Run() {
 	}
 }
 
+// outgoing is a message on its way to the browsers. Reloads for changed
+// files only go to the pages routes and dependencies say are affected.
+type outgoing struct {
+	m      Message
+	routes []Route
+}
+
+// Broadcast sends m to every connected browser, except for reloads with
+// Changed files, which only go to the browsers that depend on them.
+func (h *ReloadHub) Broadcast(m Message) {
+	h.broadcast <- outgoing{m: m}
+}
+
+// broadcastChange is Broadcast with routes mapping files to pages.
+func (h *ReloadHub) broadcastChange(m Message, routes []Route) {
+	h.broadcast <- outgoing{m: m, routes: routes}
+}
+
 func (app *Livereload) serveScript(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/javascript")
 	w.Write(app.LivereloadJS)
 }
 
 func (app *Livereload) serveWs(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: app.originAllowed}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		if app.Log != nil {
@@ -75,37 +244,165 @@ func (app *Livereload) serveWs(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	app.Hub.register <- conn
-
-	// Keep connection alive
+	client := &wsClient{queue: newQueue(r), conn: conn}
+	app.Hub.register <- client
+	go client.writePump()
 	go func() {
-		defer func() {
-			app.Hub.unregister <- conn
-		}()
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				break
-			}
+		client.readPump(app.handleBrowserMessage)
+		app.Hub.unregister <- client
+	}()
+}
+
+// handleBrowserMessage prints the errors pages forward. Other messages are
+// ignored.
+func (app *Livereload) handleBrowserMessage(message []byte) {
+	var l BrowserLog
+	if err := json.Unmarshal(message, &l); err != nil || l.Type != MessageLog {
+		return
+	}
+	text := strings.TrimSpace(l.Message)
+	first, rest, _ := strings.Cut(text, "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "Browser %s on %s: %s", printable(l.Kind), printable(l.Page), printable(first))
+	lines := strings.Split(strings.TrimSpace(rest), "\n")
+	for i, line := range strings.Split(strings.TrimSpace(l.Stack), "\n") {
+		line = strings.TrimSpace(line)
+		// Chrome repeats the message as the first line of the stack.
+		if line == "" || i == 0 && text != "" && (strings.Contains(line, text) || strings.Contains(text, line)) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			fmt.Fprintf(&b, "\n    %s", printable(line))
+		}
+	}
+	if app.Log != nil {
+		app.Log.Print(b.String())
+	} else {
+		log.Print(b.String())
+	}
+}
+
+// printable escapes the characters of s that aren't printable, as
+// strconv.Quote does, so that a page can't send escape sequences that
+// control the terminal.
+func printable(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\t' || unicode.IsPrint(r) {
+			b.WriteRune(r)
+			continue
 		}
+		q := strconv.QuoteRune(r)
+		b.WriteString(q[1 : len(q)-1])
+	}
+	return b.String()
+}
+
+// sseKeepAlive is how often an idle event stream gets a comment, so that
+// proxies don't time it out.
+const sseKeepAlive = 15 * time.Second
+
+// serveEvents streams the hub's messages as Server-Sent Events, for
+// browsers that can't open a WebSocket.
+func (app *Livereload) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	if !app.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// The page is usually served from another origin than the stream.
+	if origin := r.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Vary", "Origin")
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	client := newSSEClient(r)
+	app.Hub.register <- client
+	defer func() {
+		app.Hub.unregister <- client
 	}()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	// A browser that stops reading makes writes time out instead of
+	// blocking forever.
+	rc := http.NewResponseController(w)
+	for {
+		select {
+		case message := <-client.ch:
+			rc.SetWriteDeadline(time.Now().Add(writeWait))
+			// A line break in the data would end the field, so each
+			// line gets its own; EventSource joins them again.
+			for _, line := range strings.Split(string(message), "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(writeWait))
+			fmt.Fprint(w, ": ping\n\n")
+		case <-client.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
 }
 
-func (app *Livereload) StartServer() {
+// handler returns the handler for the livereload server.
+func (app *Livereload) handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/livereload.js", app.serveScript)
 	mux.HandleFunc("/ws", app.serveWs)
+	mux.HandleFunc("/events", app.serveEvents)
+	if app.APIToken != "" {
+		app.handleAPI(mux)
+	}
+	if app.Static != nil {
+		mux.Handle("/", app.Static)
+	}
+	return app.checkHost(mux)
+}
 
+// Scheme returns "https" if the server uses TLS and "http" otherwise.
+func (app *Livereload) Scheme() string {
+	if app.TLSCert != "" {
+		return "https"
+	}
+	return "http"
+}
+
+func (app *Livereload) StartServer() {
 	addr := fmt.Sprintf("%s:%d", app.ReloadHost, app.ReloadPort)
 	server := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: app.handler(),
 	}
 
-	app.Log.Printf("Livereload server listening on http://%s", addr)
+	app.Log.Printf("Livereload server listening on %s://%s", app.Scheme(), addr)
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if app.TLSCert != "" {
+			err = server.ListenAndServeTLS(app.TLSCert, app.TLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			app.Log.Fatalf("ListenAndServe(): %v", err)
 		}
 	}()
@@ -0,0 +1,280 @@
+package livereload
+
+import (
+	"bufio"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// waitForClients waits until n clients are registered with the hub.
+func waitForClients(t *testing.T, hub *ReloadHub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		hub.mu.Lock()
+		got := len(hub.clients)
+		hub.mu.Unlock()
+		if got == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d clients", n)
+}
+
+func TestReloadHub_Transports(t *testing.T) {
+	app := &Livereload{Hub: NewReloadHub()}
+	go app.Hub.Run()
+	srv := httptest.NewServer(app.handler())
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	resp, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %q", ct)
+	}
+	waitForClients(t, app.Hub, 2)
+
+	// Neither asked for a message version, so they get plain text.
+	messages := []string{"reload", "error:Health check failed:\nconnection refused", "reload"}
+	app.Hub.Broadcast(Message{Type: MessageReload})
+	app.Hub.Broadcast(Message{Type: MessageError, Error: "Health check failed:\nconnection refused"})
+	app.Hub.Broadcast(Message{Type: MessageReload, Changed: []string{"main.go"}})
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	for _, want := range messages {
+		_, got, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("WebSocket: expected %q, got %q", want, got)
+		}
+	}
+
+	// Read SSE events: data lines up to a blank line, ignoring comments.
+	events := bufio.NewReader(resp.Body)
+	for _, want := range messages {
+		var data []string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" && data != nil {
+				break
+			}
+			if d, ok := strings.CutPrefix(line, "data: "); ok {
+				data = append(data, d)
+			}
+		}
+		if got := strings.Join(data, "\n"); got != want {
+			t.Errorf("EventSource: expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestReloadHub_MessageVersions(t *testing.T) {
+	app := &Livereload{Hub: NewReloadHub()}
+	go app.Hub.Run()
+	srv := httptest.NewServer(app.handler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	old, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	v1, _, err := websocket.DefaultDialer.Dial(url+"?v=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v1.Close()
+	waitForClients(t, app.Hub, 2)
+
+	sent := Message{Type: MessageReload, BuildID: 3, Changed: []string{"static/app.css"}, DurationMS: 120}
+	app.Hub.Broadcast(sent)
+
+	old.SetReadDeadline(time.Now().Add(time.Second))
+	if _, got, err := old.ReadMessage(); err != nil || string(got) != "reload" {
+		t.Errorf("Version 0: expected \"reload\", got %q, %v", got, err)
+	}
+	v1.SetReadDeadline(time.Now().Add(time.Second))
+	var got Message
+	if err := v1.ReadJSON(&got); err != nil {
+		t.Fatal(err)
+	}
+	sent.V = MessageVersion
+	if !reflect.DeepEqual(got, sent) {
+		t.Errorf("Version 1: expected %+v, got %+v", sent, got)
+	}
+}
+
+// chanWriter sends everything written to it on a channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestBrowserLogs(t *testing.T) {
+	logs := make(chanWriter, 1)
+	app := &Livereload{Hub: NewReloadHub(), Log: log.New(logs, "", 0)}
+	go app.Hub.Run()
+	srv := httptest.NewServer(app.handler())
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?v=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.WriteJSON(BrowserLog{
+		Type:    MessageLog,
+		Kind:    "exception",
+		Message: "Uncaught TypeError: user is undefined",
+		Stack:   "TypeError: user is undefined\n    at render (http://localhost:8080/app.js:12:5)",
+		Page:    "http://localhost:8080/profile",
+	})
+
+	want := "Browser exception on http://localhost:8080/profile: Uncaught TypeError: user is undefined\n" +
+		"    at render (http://localhost:8080/app.js:12:5)\n"
+	select {
+	case got := <-logs:
+		if got != want {
+			t.Errorf("Expected log %q, got %q", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the browser log")
+	}
+
+	// Control characters are escaped instead of reaching the terminal.
+	ws.WriteJSON(BrowserLog{
+		Type:    MessageLog,
+		Kind:    "console.error",
+		Message: "\x1b]0;pwned\x07red \x1b[31mtext\nsecond line\u202e",
+		Page:    "http://localhost:8080/\x1b[2J",
+	})
+	want = `Browser console.error on http://localhost:8080/\x1b[2J: \x1b]0;pwned\ared \x1b[31mtext` + "\n" +
+		`    second line\u202e` + "\n"
+	select {
+	case got := <-logs:
+		if got != want {
+			t.Errorf("Expected log %q, got %q", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the browser log")
+	}
+}
+
+func TestReloadHub_DropsSlowClients(t *testing.T) {
+	app := &Livereload{Hub: NewReloadHub()}
+	go app.Hub.Run()
+	srv := httptest.NewServer(app.handler())
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	// A client whose queue is never drained.
+	slow := newSSEClient(httptest.NewRequest("GET", "/events", nil))
+	app.Hub.register <- slow
+	waitForClients(t, app.Hub, 2)
+
+	// The fast client keeps up with every message while the slow one
+	// falls behind, without holding up the hub.
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 2*sendQueueSize; i++ {
+		app.Hub.Broadcast(Message{Type: MessageReload})
+		if _, _, err := ws.ReadMessage(); err != nil {
+			t.Fatalf("Fast client: message %d: %v", i, err)
+		}
+	}
+	waitForClients(t, app.Hub, 1)
+	select {
+	case <-slow.done:
+	default:
+		t.Error("Expected the slow client to be closed")
+	}
+}
+
+func TestReloadHub_DropsDeadConnections(t *testing.T) {
+	old := pongWait
+	pongWait = 200 * time.Millisecond
+	t.Cleanup(func() { pongWait = old })
+
+	app := &Livereload{Hub: NewReloadHub()}
+	go app.Hub.Run()
+	srv := httptest.NewServer(app.handler())
+	defer srv.Close()
+
+	// The client never reads, so it never answers the pings.
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	waitForClients(t, app.Hub, 1)
+	waitForClients(t, app.Hub, 0)
+}
+
+// BenchmarkReloadHub_Broadcast measures delivering a message to hundreds of
+// browsers, a few of which have stopped reading.
+func BenchmarkReloadHub_Broadcast(b *testing.B) {
+	const clients, stalled = 300, 10
+	app := &Livereload{Hub: NewReloadHub()}
+	go app.Hub.Run()
+	srv := httptest.NewServer(app.handler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	received := make(chan bool, clients)
+	for i := 0; i < clients+stalled; i++ {
+		ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer ws.Close()
+		if i >= clients {
+			continue
+		}
+		go func() {
+			for {
+				if _, _, err := ws.ReadMessage(); err != nil {
+					return
+				}
+				received <- true
+			}
+		}()
+	}
+
+	message := Message{Type: MessageReload, Changed: []string{"main.go"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		app.Hub.Broadcast(message)
+		for j := 0; j < clients; j++ {
+			<-received
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package livereload
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StaticServer serves the files in Dir, for projects without a server of
+// their own. HTML pages get a script tag for Script injected, directories
+// without an index.html are listed, and nothing is cached by the browser.
+// Files and directories whose names start with a dot are not served.
+type StaticServer struct {
+	Dir    string
+	Script string // URL of livereload.js
+	// SPA serves Dir's index.html for paths that match no file and have
+	// no extension, so that client-side routes survive a reload.
+	SPA bool
+}
+
+// extraTypes are content types missing from some systems' MIME tables.
+var extraTypes = map[string]string{
+	".ico":         "image/x-icon",
+	".map":         "application/json",
+	".md":          "text/markdown; charset=utf-8",
+	".txt":         "text/plain; charset=utf-8",
+	".webmanifest": "application/manifest+json",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+}
+
+func (s *StaticServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	name := path.Clean("/" + r.URL.Path)
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") && part != ".well-known" {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	file := filepath.Join(s.Dir, filepath.FromSlash(name))
+	info, err := os.Stat(file)
+	if errors.Is(err, fs.ErrNotExist) && s.SPA && path.Ext(name) == "" {
+		name, file = "/index.html", filepath.Join(s.Dir, "index.html")
+		info, err = os.Stat(file)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if info.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// Relative links in the page resolve against the directory.
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		index := filepath.Join(file, "index.html")
+		if info, err = os.Stat(index); err != nil {
+			s.serveListing(w, r, file, name)
+			return
+		}
+		file = index
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(file)); {
+	case ext == ".html" || ext == ".htm":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		http.ServeContent(w, r, file, info.ModTime(), bytes.NewReader(s.inject(data)))
+	default:
+		if mime.TypeByExtension(ext) == "" {
+			if t, ok := extraTypes[ext]; ok {
+				w.Header().Set("Content-Type", t)
+			}
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		http.ServeContent(w, r, file, info.ModTime(), f)
+	}
+}
+
+// inject adds the script tag to page before </body>, or else at the end.
+func (s *StaticServer) inject(page []byte) []byte {
+	tag := []byte(fmt.Sprintf("<script src=%q></script>\n", s.Script))
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i < 0 {
+		return append(page, tag...)
+	}
+	return append(page[:i:i], append(tag, page[i:]...)...)
+}
+
+// serveListing lists the directory dir, served at name.
+func (s *StaticServer) serveListing(w http.ResponseWriter, r *http.Request, dir, name string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Directories first, then files, each by name.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+
+	var b bytes.Buffer
+	title := html.EscapeString("Index of " + name)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n<h1>%s</h1>\n<ul>\n", title, title)
+	if name != "/" {
+		fmt.Fprint(&b, "<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, e := range entries {
+		entry := e.Name()
+		if strings.HasPrefix(entry, ".") {
+			continue
+		}
+		if e.IsDir() {
+			entry += "/"
+		}
+		link := url.URL{Path: entry}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(link.String()), html.EscapeString(entry))
+	}
+	fmt.Fprint(&b, "</ul>\n</body>\n</html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(s.inject(b.Bytes())))
+}
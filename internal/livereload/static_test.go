@@ -0,0 +1,87 @@
+package livereload
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStaticServer(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":        "<html><body><h1>Home</h1></BODY></html>",
+		"about.htm":         "<p>About</p>",
+		"style.css":         "h1 { color: red }",
+		"fonts/font.woff2":  "wOF2",
+		"docs/intro.txt":    "intro",
+		".env":              "SECRET=1",
+		".git/config":       "[core]",
+		"docs/.hidden.html": "hidden",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name, path string
+		spa        bool
+		status     int
+		typ        string
+		body       string // a substring of the body
+	}{
+		{"index", "/", false, 200, "text/html", `<h1>Home</h1><script src="/livereload.js"></script>` + "\n</BODY>"},
+		{"page without body", "/about.htm", false, 200, "text/html", "<p>About</p><script src=\"/livereload.js\"></script>"},
+		{"stylesheet", "/style.css", false, 200, "text/css", "color: red"},
+		{"font", "/fonts/font.woff2", false, 200, "font/woff2", "wOF2"},
+		{"listing", "/docs/", false, 200, "text/html", `<a href="intro.txt">intro.txt</a>`},
+		{"listing script", "/docs/", false, 200, "text/html", "/livereload.js"},
+		{"directory redirect", "/docs", false, 301, "", ""},
+		{"dotfile", "/.env", false, 404, "", ""},
+		{"dot directory", "/.git/config", false, 404, "", ""},
+		{"missing", "/users/42", false, 404, "", ""},
+		{"spa fallback", "/users/42", true, 200, "text/html", "<h1>Home</h1>"},
+		{"spa missing asset", "/missing.js", true, 404, "", ""},
+	}
+	for _, tt := range tests {
+		srv := &StaticServer{Dir: dir, Script: "/livereload.js", SPA: tt.spa}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		resp := rec.Result()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, resp.StatusCode)
+			continue
+		}
+		if tt.status != 200 {
+			continue
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.typ) {
+			t.Errorf("%s: expected Content-Type %s, got %q", tt.name, tt.typ, ct)
+		}
+		if cc := resp.Header.Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("%s: expected Cache-Control no-store, got %q", tt.name, cc)
+		}
+		if !strings.Contains(string(body), tt.body) {
+			t.Errorf("%s: expected the body to contain %q, got %q", tt.name, tt.body, body)
+		}
+		if strings.Contains(string(body), ".hidden") {
+			t.Errorf("%s: expected dotfiles not to be listed", tt.name)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	(&StaticServer{Dir: dir}).ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
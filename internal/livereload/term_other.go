@@ -0,0 +1,13 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package livereload
+
+import (
+	"errors"
+	"os"
+)
+
+// Cbreak is not supported on this platform, so HandleKeys is not used.
+func Cbreak(f *os.File) (restore func() error, err error) {
+	return nil, errors.New("cbreak mode is not supported on this platform")
+}
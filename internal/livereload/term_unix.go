@@ -0,0 +1,30 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package livereload
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Cbreak puts the terminal f into cbreak mode, where each key is read as
+// soon as it is pressed and not echoed, while Ctrl-C still interrupts and
+// output is unaffected. The returned function restores the previous mode.
+func Cbreak(f *os.File) (restore func() error, err error) {
+	fd := int(f.Fd())
+	old, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	t := *old
+	t.Lflag &^= unix.ICANON | unix.ECHO
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &t); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return unix.IoctlSetTermios(fd, ioctlSetTermios, old)
+	}, nil
+}
@@ -0,0 +1,59 @@
+package livereload
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, err := SelfSignedCert(dir, []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !certCovers(certFile, keyFile, []string{"localhost", "127.0.0.1"}) {
+		t.Fatal("Expected the certificate to cover localhost and 127.0.0.1")
+	}
+	// The certificate can only serve TLS, not sign other certificates.
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := pair.Leaf
+	if cert.IsCA || !cert.BasicConstraintsValid {
+		t.Errorf("Expected a leaf certificate, got IsCA %v, BasicConstraintsValid %v", cert.IsCA, cert.BasicConstraintsValid)
+	}
+	if cert.KeyUsage != x509.KeyUsageDigitalSignature {
+		t.Errorf("Expected key usage digital signature only, got %v", cert.KeyUsage)
+	}
+	if !slices.Equal(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}) {
+		t.Errorf("Expected extended key usage server auth only, got %v", cert.ExtKeyUsage)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the key file to be private, got %v, %v", info.Mode().Perm(), err)
+	}
+	first, _ := os.ReadFile(certFile)
+
+	// A certificate that still covers the hosts is reused.
+	if _, _, err := SelfSignedCert(dir, []string{"localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(certFile); !bytes.Equal(first, again) {
+		t.Error("Expected the certificate to be reused")
+	}
+
+	// A new host requires a new certificate.
+	if _, _, err := SelfSignedCert(dir, []string{"localhost", "dev.test"}); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(certFile); bytes.Equal(first, again) {
+		t.Error("Expected a new certificate for a new host")
+	}
+	if !certCovers(certFile, keyFile, []string{"dev.test"}) {
+		t.Error("Expected the new certificate to cover dev.test")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// interpolate expands ${VAR} and ${VAR:-default} in s. Variables are looked
+// up with lookup; an unset variable without a default is an error. "$${"
+// stands for a literal "${", and any other "$" is left alone so that shell
+// expansions like $HOME and $$ pass through to the command.
+func interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '$' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(s[i+1:], "${"):
+			b.WriteString("${")
+			i += 2
+		case s[i+1] == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			expr := s[i+2 : i+2+end]
+			name, def, hasDefault := strings.Cut(expr, ":-")
+			if !validVarName(name) {
+				return "", fmt.Errorf("invalid variable name %q in %q", name, s)
+			}
+			if v, ok := lookup(name); ok && (v != "" || !hasDefault) {
+				b.WriteString(v)
+			} else if hasDefault {
+				b.WriteString(def)
+			} else {
+				return "", fmt.Errorf("variable %s is not set and has no default", name)
+			}
+			i += 2 + end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'A' && r <= 'Z') && !(r >= 'a' && r <= 'z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// interpolate expands variables in every string setting of cfg, including
+// list entries, command arguments and env values. Variables are taken from
+// lookup first and then from cfg's own env table.
+func (cfg *Config) interpolate(lookup func(string) (string, bool)) error {
+	// Expand the env table first so other settings see the expanded values.
+	withEnv := func(name string) (string, bool) {
+		if v, ok := lookup(name); ok {
+			return v, true
+		}
+		v, ok := cfg.Env[name]
+		return v, ok
+	}
+	keys := make([]string, 0, len(cfg.Env))
+	for k := range cfg.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, err := interpolate(cfg.Env[k], lookup)
+		if err != nil {
+			return fmt.Errorf("env.%s: %v", k, err)
+		}
+		cfg.Env[k] = v
+	}
+
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("toml"), ",")[0]
+		var err error
+		switch f := v.Field(i).Addr().Interface().(type) {
+		case *string:
+			*f, err = interpolate(*f, withEnv)
+		case *[]string:
+			err = interpolateAll(*f, withEnv)
+		case *Command:
+			if f.Shell, err = interpolate(f.Shell, withEnv); err == nil {
+				err = interpolateAll(f.Args, withEnv)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func interpolateAll(values []string, lookup func(string) (string, bool)) error {
+	for i, s := range values {
+		var err error
+		if values[i], err = interpolate(s, lookup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"PORT": "9090", "EMPTY": ""}
+	lookup := func(k string) (string, bool) {
+		v, ok := vars[k]
+		return v, ok
+	}
+
+	tests := []struct {
+		in, want, err string
+	}{
+		{in: "http://localhost:${PORT}/healthz", want: "http://localhost:9090/healthz"},
+		{in: "http://localhost:${PORT:-8080}", want: "http://localhost:9090"},
+		{in: "http://localhost:${MISSING:-8080}", want: "http://localhost:8080"},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "${EMPTY}", want: ""},
+		{in: "echo $HOME $$ $$PORT $${PORT} $$${PORT}", want: "echo $HOME $$ $$PORT ${PORT} $${PORT}"},
+		{in: "trailing $", want: "trailing $"},
+		{in: "${MISSING}", err: "MISSING is not set"},
+		{in: "${PORT", err: "unterminated"},
+		{in: "${1ABC}", err: "invalid variable name"},
+	}
+	for _, tt := range tests {
+		got, err := interpolate(tt.in, lookup)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("interpolate(%q): expected error containing %q, got %q, %v", tt.in, tt.err, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("interpolate(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_Interpolation(t *testing.T) {
+	t.Setenv("LR_TEST_PORT", "9090")
+	path := writeConfig(t, t.TempDir(), `
+run = ["./app", "--port", "${LR_TEST_PORT}"]
+health_url = "http://localhost:${LR_TEST_PORT:-8080}/healthz"
+watch = ["${SRC_DIR:-.}"]
+
+[env]
+SRC_DIR = "src"
+`)
+	cfg, err := loadConfig(path, "", defaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Run.Args, []string{"./app", "--port", "9090"}) {
+		t.Errorf("Unexpected run command %v", cfg.Run.Args)
+	}
+	if cfg.HealthURL != "http://localhost:9090/healthz" {
+		t.Errorf("Unexpected health_url %q", cfg.HealthURL)
+	}
+	if !strings.HasSuffix(cfg.Watch[0], "src") {
+		t.Errorf("Expected watch from the env table, got %v", cfg.Watch)
+	}
+
+	path = writeConfig(t, t.TempDir(), `run = "./app --port ${LR_TEST_UNDEFINED}"`)
+	if _, err := loadConfig(path, "", defaultConfig()); err == nil || !strings.Contains(err.Error(), "run: variable LR_TEST_UNDEFINED is not set") {
+		t.Errorf("Expected undefined variable error, got %v", err)
+	}
+}
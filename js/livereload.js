@@ -1,22 +1,376 @@
 (function() {
-    var socket = new WebSocket("ws://localhost:35729/ws");
+    // The server that served this script also serves the socket and the
+    // event stream.
+    var script = document.currentScript;
+    var base = new URL(script && script.src ? script.src : "http://localhost:35729/livereload.js");
+    var httpBase = base.protocol + "//" + base.host;
+    var wsBase = (base.protocol === "https:" ? "wss:" : "ws:") + "//" + base.host;
 
-    socket.onopen = function() {
-        console.log("Livereload connected");
-    };
+    // VERSION is the message envelope version this script understands.
+    var VERSION = 1;
+
+    // The page registers its URL, and the files listed in the script tag's
+    // data-deps attribute, so that only changes affecting it reload it.
+    var query = "?v=" + VERSION + "&page=" + encodeURIComponent(window.location.href);
+    var deps = script && script.getAttribute("data-deps");
+    if (deps) {
+        query += "&deps=" + encodeURIComponent(deps);
+    }
 
-    socket.onmessage = function(event) {
-        if (event.data === "reload") {
-            console.log("Reloading...");
-            window.location.reload();
+    // Scroll positions and form fields are kept across reloads unless the
+    // script tag has data-restore="false". Fields the user can't edit or
+    // that hold no input of theirs never are, so that fresh values from the
+    // server, such as CSRF tokens in hidden inputs, win.
+    var STATE_KEY = "livereload-state";
+    var restore = !script || script.getAttribute("data-restore") !== "false";
+
+    // fieldKey identifies a form field across reloads: by id, else by
+    // name and position among the fields with that name, else by position.
+    function fieldKey(field, index, seen) {
+        if (field.id) {
+            return "#" + field.id;
         }
-    };
+        if (field.name) {
+            var key = field.tagName + "[" + field.name + "]";
+            seen[key] = (seen[key] || 0) + 1;
+            return key + seen[key];
+        }
+        return field.tagName + ":" + index;
+    }
 
-    socket.onclose = function() {
-        console.log("Livereload disconnected");
-    };
+    var SKIPPED_TYPES = {password: true, file: true, hidden: true, submit: true, button: true, reset: true, image: true};
+
+    function eachField(fn) {
+        var fields = document.querySelectorAll("input, textarea, select");
+        var seen = {};
+        for (var i = 0; i < fields.length; i++) {
+            var type = (fields[i].type || "").toLowerCase();
+            if (SKIPPED_TYPES[type] || fields[i].readOnly || fields[i].disabled) {
+                continue;
+            }
+            fn(fields[i], fieldKey(fields[i], i, seen));
+        }
+    }
+
+    function saveState() {
+        var state = {url: window.location.href, x: window.scrollX, y: window.scrollY, fields: {}, scrolls: {}};
+        eachField(function(field, key) {
+            if (field.type === "checkbox" || field.type === "radio") {
+                state.fields[key] = {checked: field.checked};
+            } else if (field.multiple) {
+                state.fields[key] = {selected: Array.prototype.map.call(field.options, function(o) { return o.selected; })};
+            } else {
+                state.fields[key] = {value: field.value};
+            }
+        });
+        var scrolled = document.querySelectorAll("[id]");
+        for (var i = 0; i < scrolled.length; i++) {
+            if (scrolled[i].scrollTop || scrolled[i].scrollLeft) {
+                state.scrolls[scrolled[i].id] = [scrolled[i].scrollLeft, scrolled[i].scrollTop];
+            }
+        }
+        sessionStorage.setItem(STATE_KEY, JSON.stringify(state));
+    }
+
+    function restoreState() {
+        var state;
+        try {
+            state = JSON.parse(sessionStorage.getItem(STATE_KEY));
+            sessionStorage.removeItem(STATE_KEY);
+        } catch (e) {
+            return;
+        }
+        if (!state || state.url !== window.location.href) {
+            return;
+        }
+        function restoreFields() {
+            eachField(function(field, key) {
+                var saved = state.fields[key];
+                if (!saved) {
+                    return;
+                }
+                if ("checked" in saved) {
+                    field.checked = saved.checked;
+                } else if (saved.selected) {
+                    for (var i = 0; i < field.options.length; i++) {
+                        field.options[i].selected = !!saved.selected[i];
+                    }
+                } else {
+                    field.value = saved.value;
+                }
+            });
+            for (var id in state.scrolls) {
+                var el = document.getElementById(id);
+                if (el) {
+                    el.scrollLeft = state.scrolls[id][0];
+                    el.scrollTop = state.scrolls[id][1];
+                }
+            }
+        }
+        // The page may only be tall enough to scroll once images and
+        // styles have loaded.
+        function restoreScroll() {
+            window.scrollTo(state.x, state.y);
+        }
+        if (document.readyState === "loading") {
+            document.addEventListener("DOMContentLoaded", restoreFields);
+        } else {
+            restoreFields();
+        }
+        if (document.readyState === "complete") {
+            restoreScroll();
+        } else {
+            window.addEventListener("load", restoreScroll);
+        }
+    }
+
+    function reload() {
+        if (restore) {
+            try {
+                saveState();
+            } catch (e) {
+                // sessionStorage may be unavailable or full.
+            }
+        }
+        window.location.reload();
+    }
+
+    if (restore) {
+        restoreState();
+    }
+
+    function showOverlay(message) {
+        var overlay = document.getElementById("livereload-overlay");
+        if (!overlay) {
+            overlay = document.createElement("div");
+            overlay.id = "livereload-overlay";
+            overlay.style.cssText = "position:fixed;top:0;left:0;right:0;bottom:0;z-index:2147483647;" +
+                "background:rgba(0,0,0,0.85);color:#ff6b6b;font:14px/1.5 monospace;" +
+                "padding:2em;white-space:pre-wrap;overflow:auto";
+            document.body.appendChild(overlay);
+        }
+        overlay.textContent = "livereload: " + message;
+    }
 
-    socket.onerror = function(error) {
-        console.error("Livereload error: " + error);
+    // The badge in the corner of the page shows the progress of a build
+    // and restart, unless the script tag has data-badge="false".
+    var showBadge = !script || script.getAttribute("data-badge") !== "false";
+    var BADGE_STATES = {
+        building: {text: "Building\u2026", color: "#b7791f"},
+        starting: {text: "Starting\u2026", color: "#b7791f"},
+        ready: {text: "Ready", color: "#2f855a"},
+        failed: {text: "Failed", color: "#c53030"},
+        reload: {text: "Reloading\u2026", color: "#2f855a"}
     };
+    var badgeTimer = null;
+
+    function setBadge(state, detail) {
+        var look = BADGE_STATES[state];
+        if (!showBadge || !look || !document.body) {
+            return;
+        }
+        var badge = document.getElementById("livereload-badge");
+        if (!badge) {
+            badge = document.createElement("div");
+            badge.id = "livereload-badge";
+            badge.style.cssText = "position:fixed;right:12px;bottom:12px;z-index:2147483646;" +
+                "padding:4px 10px;border-radius:12px;color:#fff;font:12px/1.5 sans-serif;" +
+                "box-shadow:0 1px 4px rgba(0,0,0,0.3);opacity:0.9;pointer-events:none";
+            document.body.appendChild(badge);
+        }
+        badge.textContent = "livereload: " + look.text;
+        badge.title = detail || "";
+        badge.style.background = look.color;
+        badge.style.display = "";
+        clearTimeout(badgeTimer);
+        if (state === "ready") {
+            badgeTimer = setTimeout(function() {
+                badge.style.display = "none";
+            }, 2000);
+        }
+    }
+
+    // parseMessage returns the message envelope in data. Servers from
+    // before the envelope send plain "reload" and "error:<text>".
+    function parseMessage(data) {
+        if (data === "reload") {
+            return {type: "reload"};
+        }
+        if (data.indexOf("error:") === 0) {
+            return {type: "error", error: data.slice("error:".length)};
+        }
+        try {
+            return JSON.parse(data);
+        } catch (e) {
+            return null;
+        }
+    }
+
+    function handleMessage(data) {
+        var message = parseMessage(data);
+        if (!message) {
+            return;
+        }
+        if (message.type === "reload") {
+            console.log("Reloading..." + (message.changed ? " (" + message.changed.join(", ") + " changed)" : ""));
+            setBadge("reload");
+            reload();
+        } else if (message.type === "error") {
+            setBadge("failed", message.error);
+            showOverlay(message.error);
+        } else {
+            setBadge(message.type, message.error);
+        }
+    }
+
+    // A connection that drops, for instance because the computer slept or
+    // the server dropped a page that stopped answering, is opened again,
+    // waiting longer after each failed attempt.
+    var RETRY_MIN = 500;
+    var RETRY_MAX = 10000;
+    var retryDelay = RETRY_MIN;
+
+    function retry() {
+        console.log("Livereload: reconnecting in " + retryDelay / 1000 + "s");
+        setTimeout(connect, retryDelay);
+        retryDelay = Math.min(retryDelay * 2, RETRY_MAX);
+    }
+
+    // connect opens a WebSocket, falling back to the event stream where
+    // WebSockets are blocked, e.g. by a proxy.
+    function connect() {
+        if (window.WebSocket) {
+            connectWebSocket();
+        } else {
+            connectEventSource();
+        }
+    }
+
+    function connectEventSource() {
+        if (!window.EventSource) {
+            retry();
+            return;
+        }
+        var opened = false;
+        var source = new EventSource(httpBase + "/events" + query);
+        source.onopen = function() {
+            opened = true;
+            retryDelay = RETRY_MIN;
+            console.log("Livereload connected (EventSource)");
+        };
+        source.onmessage = function(event) {
+            handleMessage(event.data);
+        };
+        // EventSource gives up by itself after some errors, so it is
+        // reconnected like the WebSocket instead.
+        source.onerror = function() {
+            source.close();
+            if (opened) {
+                console.log("Livereload disconnected");
+            }
+            retry();
+        };
+    }
+
+    function connectWebSocket() {
+        var opened = false;
+        var socket;
+        try {
+            socket = new WebSocket(wsBase + "/ws" + query);
+        } catch (e) {
+            connectEventSource();
+            return;
+        }
+
+        socket.onopen = function() {
+            opened = true;
+            retryDelay = RETRY_MIN;
+            console.log("Livereload connected");
+            openSocket = socket;
+            flushLogs();
+        };
+
+        socket.onmessage = function(event) {
+            handleMessage(event.data);
+        };
+
+        socket.onclose = function() {
+            if (!opened) {
+                console.log("Livereload: WebSocket unavailable, falling back to EventSource");
+                connectEventSource();
+                return;
+            }
+            openSocket = null;
+            console.log("Livereload disconnected");
+            retry();
+        };
+
+        socket.onerror = function(error) {
+            if (opened) {
+                console.error("Livereload error: " + error);
+            }
+        };
+    }
+
+    // With data-forward-console on the script tag, console.error calls,
+    // uncaught exceptions and unhandled promise rejections are printed by
+    // livereload too. They need the WebSocket; the event stream only
+    // goes one way.
+    var MAX_LOGS = 100;
+    var openSocket = null;
+    var pendingLogs = [];
+    var sentLogs = 0;
+
+    function flushLogs() {
+        while (openSocket && pendingLogs.length) {
+            openSocket.send(JSON.stringify(pendingLogs.shift()));
+        }
+    }
+
+    function forwardLog(kind, message, stack) {
+        // Don't flood the terminal with an error thrown in a loop.
+        if (sentLogs >= MAX_LOGS) {
+            return;
+        }
+        sentLogs++;
+        pendingLogs.push({type: "log", kind: kind, message: String(message), stack: stack || "", page: window.location.href});
+        flushLogs();
+    }
+
+    function formatArg(arg) {
+        if (arg instanceof Error) {
+            return arg.stack || arg.message;
+        }
+        if (typeof arg === "string") {
+            return arg;
+        }
+        try {
+            return JSON.stringify(arg);
+        } catch (e) {
+            return String(arg);
+        }
+    }
+
+    if (script && script.hasAttribute("data-forward-console") && script.getAttribute("data-forward-console") !== "false") {
+        var consoleError = console.error;
+        console.error = function() {
+            forwardLog("console.error", Array.prototype.map.call(arguments, formatArg).join(" "));
+            return consoleError.apply(console, arguments);
+        };
+        window.addEventListener("error", function(event) {
+            var stack = event.error && event.error.stack;
+            if (!stack && event.filename) {
+                stack = event.filename + ":" + event.lineno + ":" + event.colno;
+            }
+            forwardLog("exception", event.message, stack);
+        });
+        window.addEventListener("unhandledrejection", function(event) {
+            var reason = event.reason;
+            forwardLog("rejection", reason && reason.message ? reason.message : formatArg(reason), reason && reason.stack);
+        });
+    }
+
+    if (window.WebSocket || window.EventSource) {
+        connect();
+    }
 })();
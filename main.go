@@ -5,118 +5,485 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/pelletier/go-toml/v2"
 	"github.com/spwg/livereload/internal/livereload"
 )
 
 //go:embed js/livereload.js
 var LivereloadJs []byte
 
-type Config struct {
-	Build     string   `toml:"build"`
-	Run       string   `toml:"run"`
-	Watch     []string `toml:"watch"`
-	Ignore    []string `toml:"ignore"`
-	Delay     int      `toml:"delay"`
-	HealthURL string   `toml:"health_url"`
+func main() {
+	if err := runCommand(os.Args[1:]); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 }
 
-func main() {
-	var (
-		buildCmd   string
-		runCmd     string
-		watchPaths string
-		ignoreDirs string
-		port       int
-		host       string
-		delay      int
-		healthURL  string
-	)
-
-	flag.StringVar(&buildCmd, "build", "", "Command to build the project")
-	flag.StringVar(&runCmd, "run", "", "Command to run the executable")
-	flag.StringVar(&watchPaths, "watch", "", "Comma-separated list of directories/files to watch")
-	flag.StringVar(&ignoreDirs, "ignore", "", "Comma-separated list of directories to ignore")
-	flag.IntVar(&port, "port", 35729, "Port for the livereload server")
-	flag.StringVar(&host, "host", "localhost", "Host for the livereload server")
-	flag.IntVar(&delay, "delay", 100, "Delay in milliseconds after restart before reload (fallback if no health_url)")
-	flag.StringVar(&healthURL, "health-url", "", "URL to poll for health check before reloading")
-	flag.Parse()
+// setupRun defines the flags of "livereload run", which builds and runs the
+// app and reloads the browser whenever a watched file changes.
+func setupRun(fs *flag.FlagSet) func(args []string) error {
+	var configPath, profile string
+	fs.StringVar(&configPath, "config", os.Getenv("LIVERELOAD_CONFIG"), "Path to the config file (default: search upward for livereload.toml, .yaml, .yml or .json from the current directory)")
+	fs.StringVar(&profile, "profile", os.Getenv("LIVERELOAD_PROFILE"), "Name of a profile in the config file to apply")
+	setFlags := settingFlags(fs)
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected argument %q", args[0])
+		}
+		return runLivereload(configPath, profile, setFlags())
+	}
+}
 
-	// Load config from file
-	var cfg Config
-	if data, err := os.ReadFile("livereload.toml"); err == nil {
-		if err := toml.Unmarshal(data, &cfg); err != nil {
-			log.Fatalf("Failed to parse livereload.toml: %v", err)
+// setupServe defines the flags of "livereload serve", which is "livereload
+// run" serving the files in a directory, by default the one from the config
+// file or else the current one.
+func setupServe(fs *flag.FlagSet) func(args []string) error {
+	var configPath, profile string
+	fs.StringVar(&configPath, "config", os.Getenv("LIVERELOAD_CONFIG"), "Path to the config file (default: search upward for livereload.toml, .yaml, .yml or .json from the current directory)")
+	fs.StringVar(&profile, "profile", os.Getenv("LIVERELOAD_PROFILE"), "Name of a profile in the config file to apply")
+	setFlags := settingFlags(fs)
+	return func(args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("unexpected argument %q", args[1])
 		}
-		fmt.Println("Loaded configuration from livereload.toml")
+		flags := setFlags()
+		if len(args) == 1 {
+			flags["serve"] = args[0]
+		} else if _, ok := flags["serve"]; !ok {
+			path := configPath
+			if path == "" {
+				path, _ = findConfig(".")
+			}
+			if cfg, err := resolveConfig(path, profile, os.Getenv, flags); err != nil || cfg.Serve == "" {
+				flags["serve"] = "."
+			}
+		}
+		return runLivereload(configPath, profile, flags)
 	}
+}
 
-	// Override with flags if set
-	if buildCmd != "" {
-		cfg.Build = buildCmd
+// runLivereload watches, builds and runs the app as configured by the
+// config file at configPath, searched for if empty, and flags, the config
+// keys set with flags.
+func runLivereload(configPath, profile string, flags map[string]string) error {
+	// Load config from file
+	if configPath == "" {
+		found, err := findConfig(".")
+		if err != nil {
+			return err
+		}
+		configPath = found
 	}
-	if runCmd != "" {
-		cfg.Run = runCmd
+	if configPath != "" {
+		if profile != "" {
+			fmt.Printf("Loaded configuration from %s (profile %q)\n", configPath, profile)
+		} else {
+			fmt.Printf("Loaded configuration from %s\n", configPath)
+		}
+	} else if profile != "" {
+		return fmt.Errorf("--profile %q given but no config file was found", profile)
 	}
-	if watchPaths != "" {
-		cfg.Watch = strings.Split(watchPaths, ",")
+
+	// load layers the config file, the environment and the flags. It runs
+	// at startup and again whenever the config file changes.
+	load := func() (Config, error) {
+		cfg, err := resolveConfig(configPath, profile, os.Getenv, flags)
+		if err != nil {
+			return cfg, err
+		}
+		return cfg, cfg.Validate()
 	}
-	if ignoreDirs != "" {
-		cfg.Ignore = strings.Split(ignoreDirs, ",")
+
+	cfg, err := load()
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%v", err)
 	}
-	if delay >= 0 {
-		cfg.Delay = delay
+	current, err := newSettings(cfg)
+	if err != nil {
+		return err
 	}
-	if healthURL != "" {
-		cfg.HealthURL = healthURL
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
 	}
+	realWatcher := &livereload.RealWatcher{Watcher: fsWatcher}
+	defer realWatcher.Close()
 
-	// Defaults if nothing set
-	if len(cfg.Watch) == 0 {
-		cfg.Watch = []string{"."}
+	// Recursively add paths using the helper from the package
+	if err := livereload.AddRecursiveWatch(realWatcher, cfg.Watch, current.ignoreMap); err != nil {
+		return err
 	}
-	if len(cfg.Ignore) == 0 {
-		cfg.Ignore = []string{".git", "node_modules"}
+	if err := watchEnvFiles(realWatcher, cfg.EnvFile); err != nil {
+		return err
 	}
 
-	if cfg.Run == "" {
-		log.Fatal("Error: --run flag or 'run' in livereload.toml is required")
+	output := &livereload.Output{W: os.Stdout, Prefix: cfg.OutputPrefix}
+	switch cfg.Color {
+	case "", "auto":
+		output.Color = livereload.IsTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	case "always":
+		output.Color = true
+	}
+	// The log probe changes when the config is reloaded, while the output
+	// is being written from other goroutines.
+	var logProbe atomic.Pointer[livereload.LogProbe]
+	output.OnLine = func(label string, line []byte) {
+		if p := logProbe.Load(); p != nil && label == "run" {
+			p.Observe(line)
+		}
 	}
+	if cfg.LogFile != "" {
+		logWriter := &livereload.RotatingFile{
+			Path:       cfg.LogFile,
+			MaxSize:    int64(cfg.LogMaxSize) << 20,
+			MaxBackups: cfg.LogMaxFiles,
+		}
+		defer logWriter.Close()
+		output.Tee = logWriter
+	}
+	runner := &livereload.RealCommandRunner{Output: output}
 
-	ignoreMap := make(map[string]bool)
-	for _, dir := range cfg.Ignore {
-		ignoreMap[strings.TrimSpace(dir)] = true
+	app := livereload.NewLivereload(cfg.Build.Shell, cfg.Run.Shell, current.ignoreMap, realWatcher, cfg.Port, cfg.Host, LivereloadJs)
+	app.Runner = runner
+	current.apply(app, runner, &logProbe)
+	if cfg.TLSSelfSigned {
+		if cfg.TLSCert, cfg.TLSKey, err = selfSignedCert(cfg.Host); err != nil {
+			return fmt.Errorf("failed to create a self-signed certificate: %v", err)
+		}
+	}
+	app.TLSCert, app.TLSKey = cfg.TLSCert, cfg.TLSKey
+	if cfg.Serve != "" {
+		app.Static = &livereload.StaticServer{Dir: cfg.Serve, Script: "/livereload.js", SPA: cfg.ServeSPA}
 	}
 
-	fsWatcher, err := fsnotify.NewWatcher()
+	// The control API lets editors and scripts drive the session.
+	if app.APIToken, err = livereload.NewToken(); err != nil {
+		return err
+	}
+	removeSession, err := writeSession(cfg.Host, cfg.Port, app.APIToken, cfg.TLSCert)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to write session file: %v", err)
 	}
-	realWatcher := &livereload.RealWatcher{Watcher: fsWatcher}
-	defer realWatcher.Close()
+	defer removeSession()
 
-	// Recursively add paths using the helper from the package
-	if err := livereload.AddRecursiveWatch(realWatcher, cfg.Watch, ignoreMap); err != nil {
-		log.Fatal(err)
-	}
+	if configPath != "" {
+		// Watch the config file and apply changes to the running app.
+		if err := realWatcher.Add(configPath); err != nil {
+			return fmt.Errorf("failed to watch %s: %v", configPath, err)
+		}
+		var mu sync.Mutex
+		app.ConfigFile = configPath
+		app.OnConfigChange = func() {
+			mu.Lock()
+			defer mu.Unlock()
 
-	app := livereload.NewLivereload(cfg.Build, cfg.Run, ignoreMap, realWatcher, port, host, LivereloadJs)
-	app.RestartDelay = time.Duration(cfg.Delay) * time.Millisecond
-	app.HealthURL = cfg.HealthURL
+			// Editors that save by renaming a new file into place drop
+			// the watch on the old one.
+			realWatcher.Add(configPath)
+
+			cfg, err := load()
+			var next *settings
+			if err == nil {
+				next, err = newSettings(cfg)
+			}
+			if err != nil {
+				fmt.Printf(">> Invalid configuration, keeping the previous one: %v\n", err)
+				return
+			}
+			for _, key := range restartOnlyChanges(current.cfg, next.cfg) {
+				fmt.Printf(">> Changing %s requires restarting livereload\n", key)
+			}
+
+			prev := current
+			app.Reconfigure(func(app *livereload.Livereload) {
+				// Re-add every root so changes to ignore rules apply to
+				// directories that are already watched.
+				livereload.RemoveRecursiveWatch(realWatcher, prev.cfg.Watch)
+				if err := livereload.AddRecursiveWatch(realWatcher, next.cfg.Watch, next.ignoreMap); err != nil {
+					app.Log.Printf("Failed to watch %v: %v", next.cfg.Watch, err)
+				}
+				if err := watchEnvFiles(realWatcher, next.cfg.EnvFile); err != nil {
+					app.Log.Print(err)
+				}
+				next.apply(app, runner, &logProbe)
+			})
+			current = next
+		}
+	}
 
 	fmt.Printf("Livereload started.\n")
 	fmt.Printf("Build command: %s\n", cfg.Build)
 	fmt.Printf("Run command: %s\n", cfg.Run)
 	fmt.Printf("Watching: %v\n", cfg.Watch)
-	fmt.Printf("Livereload Server: http://%s:%d/livereload.js\n", host, port)
+	if cfg.Dir != "" {
+		fmt.Printf("Working directory: %s\n", cfg.Dir)
+	}
+	if cfg.Serve != "" {
+		fmt.Printf("Serving: %s at %s://%s:%d/\n", cfg.Serve, app.Scheme(), cfg.Host, cfg.Port)
+	}
+	fmt.Printf("Livereload Server: %s://%s:%d/livereload.js\n", app.Scheme(), cfg.Host, cfg.Port)
+	fmt.Printf("Control API: %s://%s:%d/api (token %s)\n", app.Scheme(), cfg.Host, cfg.Port, app.APIToken)
+	if !isLoopback(cfg.Host) {
+		fmt.Printf(">> Warning: the livereload server on %s is reachable from other machines; only pages from %s may connect (see allowed_origins)\n",
+			cfg.Host, strings.Join(current.origins, ", "))
+	}
+	if cfg.TLSSelfSigned {
+		fmt.Printf("Certificate: %s (open the script URL once and accept it in the browser)\n", cfg.TLSCert)
+	}
+
+	// Keys are read from the terminal in cbreak mode, which fails when
+	// stdin is not a terminal.
+	restoreTerm := func() error { return nil }
+	if restore, err := livereload.Cbreak(os.Stdin); err == nil {
+		restoreTerm = restore
+		defer restore()
+		go app.HandleKeys(os.Stdin)
+	}
+	// Deferred calls don't run when interrupted.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		runner.Stop()
+		restoreTerm()
+		removeSession()
+		os.Exit(130)
+	}()
+
+	return app.Run()
+}
+
+// selfSignedCert returns the self-signed certificate kept in the user's
+// cache directory, valid for localhost and host.
+func selfSignedCert(host string) (certFile, keyFile string, err error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if ip := net.ParseIP(host); host != "" && !slices.Contains(hosts, host) && (ip == nil || !ip.IsUnspecified()) {
+		hosts = append(hosts, host)
+	}
+	return livereload.SelfSignedCert(filepath.Join(dir, "livereload"), hosts)
+}
+
+// settingFlag is a flag for a config key. Its value is kept as given and
+// parsed by Config.set, like the matching environment variable.
+type settingFlag struct {
+	key, value string
+}
+
+func (f *settingFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *settingFlag) Set(v string) error {
+	f.value = v
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	return isBoolKey(f.key)
+}
+
+// settingFlags defines a flag on fs for every config key in settingUsage.
+// The returned function reports the flags set explicitly on the command
+// line, so that defaults never override the config file.
+func settingFlags(fs *flag.FlagSet) func() map[string]string {
+	for _, s := range settingUsage {
+		fs.Var(&settingFlag{key: s.key}, flagName(s.key), s.usage)
+	}
+	return func() map[string]string {
+		set := make(map[string]string)
+		fs.Visit(func(f *flag.Flag) {
+			if sf, ok := f.Value.(*settingFlag); ok {
+				set[sf.key] = sf.value
+			}
+		})
+		return set
+	}
+}
+
+// settings are the values derived from a Config for the running app. They
+// are rebuilt and applied again whenever the config file changes.
+type settings struct {
+	cfg       Config
+	ignoreMap map[string]bool
+	health    livereload.Probe
+	logProbe  *livereload.LogProbe
+	policy    livereload.HealthPolicy
+	origins   []string
+	routes    []livereload.Route
+}
+
+func newSettings(cfg Config) (*settings, error) {
+	s := &settings{cfg: cfg, ignoreMap: make(map[string]bool)}
+	for _, dir := range cfg.Ignore {
+		s.ignoreMap[strings.TrimSpace(dir)] = true
+	}
+	if cfg.LogFile != "" {
+		// Writing the log must not trigger a restart.
+		base := filepath.Base(cfg.LogFile)
+		s.ignoreMap[base] = true
+		for i := 1; i <= cfg.LogMaxFiles; i++ {
+			s.ignoreMap[fmt.Sprintf("%s.%d", base, i)] = true
+		}
+	}
+
+	var err error
+	if s.health, s.logProbe, err = healthProbes(cfg); err != nil {
+		return nil, err
+	}
+	if s.policy, err = livereload.ParseHealthPolicy(cfg.HealthPolicy); err != nil {
+		return nil, err
+	}
+	s.origins = allowedOrigins(cfg)
+	for _, r := range cfg.Routes {
+		s.routes = append(s.routes, livereload.Route{Files: r.Files, Pages: r.Pages})
+	}
+	return s, nil
+}
+
+// apply copies the settings into app, its runner and the log probe fed by
+// the runner's output.
+func (s *settings) apply(app *livereload.Livereload, runner *livereload.RealCommandRunner, logProbe *atomic.Pointer[livereload.LogProbe]) {
+	cfg := s.cfg
+	app.BuildCmd = cfg.Build.Shell
+	app.RunCmd = cfg.Run.Shell
+	app.BuildArgs = cfg.Build.Args
+	app.RunArgs = cfg.Run.Args
+	app.IgnoreMap = s.ignoreMap
+	app.WatchRoots = cfg.Watch
+	app.RestartDelay = time.Duration(cfg.Delay) * time.Millisecond
+	app.DebounceTime = time.Duration(cfg.Debounce) * time.Millisecond
+	app.Health = s.health
+	app.HealthTimeout = time.Duration(cfg.HealthTimeout) * time.Millisecond
+	app.HealthInterval = time.Duration(cfg.HealthInterval) * time.Millisecond
+	app.HealthPolicy = s.policy
+	app.AllowedOrigins = s.origins
+	app.Routes = s.routes
+	runner.Dir = cfg.Dir
+	runner.Env = cfg.Env
+	runner.EnvFiles = cfg.EnvFile
+	logProbe.Store(s.logProbe)
+}
+
+// allowedOrigins returns the origins that may connect: those configured,
+// or else localhost and the app's own origin, taken from health_url.
+func allowedOrigins(cfg Config) []string {
+	var origins []string
+	for _, o := range cfg.AllowedOrigins {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	if len(origins) > 0 {
+		return origins
+	}
+	origins = append(origins, livereload.LocalHosts...)
+	if u, err := url.Parse(cfg.HealthURL); err == nil && cfg.HealthURL != "" {
+		origins = append(origins, u.Scheme+"://"+u.Host)
+	}
+	return origins
+}
+
+// isLoopback reports whether host only accepts connections from this
+// machine.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// restartOnlyChanges returns the keys that differ between old and new but
+// are only read at startup.
+func restartOnlyChanges(old, new Config) []string {
+	var keys []string
+	if old.Port != new.Port || old.Host != new.Host {
+		keys = append(keys, "port and host")
+	}
+	if old.OutputPrefix != new.OutputPrefix {
+		keys = append(keys, "output_prefix")
+	}
+	if old.Color != new.Color {
+		keys = append(keys, "color")
+	}
+	if old.LogFile != new.LogFile || old.LogMaxSize != new.LogMaxSize || old.LogMaxFiles != new.LogMaxFiles {
+		keys = append(keys, "log_file")
+	}
+	if old.Serve != new.Serve || old.ServeSPA != new.ServeSPA {
+		keys = append(keys, "serve")
+	}
+	if old.TLSCert != new.TLSCert || old.TLSKey != new.TLSKey || old.TLSSelfSigned != new.TLSSelfSigned {
+		keys = append(keys, "the TLS settings")
+	}
+	return keys
+}
+
+// watchEnvFiles watches the env files too, so editing them restarts the
+// app with the new values.
+func watchEnvFiles(watcher livereload.FileWatcher, envFiles []string) error {
+	for _, f := range envFiles {
+		if err := watcher.Add(f); err != nil {
+			return fmt.Errorf("failed to watch env file %s: %v", f, err)
+		}
+	}
+	return nil
+}
+
+// healthProbes builds the readiness probes selected in cfg. Every
+// configured probe must pass before the browser is reloaded. The LogProbe,
+// if any, is returned separately so it can be fed the app's output.
+func healthProbes(cfg Config) (livereload.Probe, *livereload.LogProbe, error) {
+	var probes livereload.AllProbes
+	var logProbe *livereload.LogProbe
+
+	if cfg.HealthURL != "" {
+		p := &livereload.HTTPProbe{URL: cfg.HealthURL, Status: cfg.HealthStatus}
+		if cfg.HealthBody != "" {
+			re, err := regexp.Compile(cfg.HealthBody)
+			if err != nil {
+				return nil, nil, fmt.Errorf("health_body: %v", err)
+			}
+			p.Body = re
+		}
+		probes = append(probes, p)
+	}
+	if cfg.HealthTCP != "" {
+		probes = append(probes, &livereload.TCPProbe{Addr: cfg.HealthTCP})
+	}
+	if cfg.HealthCmd != "" {
+		probes = append(probes, &livereload.CommandProbe{Cmd: cfg.HealthCmd, Dir: cfg.Dir, Env: cfg.Env, EnvFiles: cfg.EnvFile})
+	}
+	if cfg.HealthLog != "" {
+		re, err := regexp.Compile(cfg.HealthLog)
+		if err != nil {
+			return nil, nil, fmt.Errorf("health_log: %v", err)
+		}
+		logProbe = &livereload.LogProbe{Pattern: re}
+		probes = append(probes, logProbe)
+	}
 
-	if err := app.Run(); err != nil {
-		log.Fatal(err)
+	if len(probes) == 0 {
+		return nil, nil, nil
 	}
+	return probes, logProbe, nil
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// configSchema returns a JSON Schema describing the config file, for
+// editors to validate and complete livereload.json and livereload.yaml.
+// It is generated from Config so it can't fall out of step with it.
+func configSchema() map[string]any {
+	usage := map[string]string{
+		"env":    "Environment variables for the build and run commands, added to livereload's own",
+		"routes": "Which pages use which files, so that changing them only reloads those pages",
+		"files":  "Patterns of the files the pages use; ** matches any number of directories",
+		"pages":  "Patterns of the URL paths of the pages",
+	}
+	for _, s := range settingUsage {
+		usage[s.key] = strings.ReplaceAll(s.usage, "`", "")
+	}
+
+	props := make(map[string]any)
+	defaults := reflect.ValueOf(defaultConfig())
+	t := defaults.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		prop := schemaType(t.Field(i).Type, usage)
+		if d, ok := usage[key]; ok {
+			prop["description"] = d
+		}
+		if v := defaults.Field(i); !v.IsZero() {
+			prop["default"] = v.Interface()
+		}
+		props[key] = prop
+	}
+
+	top := map[string]any{
+		"$schema": map[string]any{"type": "string"},
+		"profile": map[string]any{
+			"description": "Named sets of settings that override the top-level ones when selected with --profile",
+			"type":        "object",
+			"additionalProperties": map[string]any{
+				"$ref": "#/definitions/settings",
+			},
+		},
+	}
+	for k, v := range props {
+		top[k] = v
+	}
+	return map[string]any{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "livereload configuration",
+		"type":                 "object",
+		"properties":           top,
+		"additionalProperties": false,
+		"definitions": map[string]any{
+			"settings": map[string]any{
+				"type":                 "object",
+				"properties":           props,
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+// schemaType returns the JSON Schema for a Config field of type t. usage
+// describes the fields of structs.
+func schemaType(t reflect.Type, usage map[string]string) map[string]any {
+	if t == reflect.TypeOf(Command{}) {
+		return map[string]any{
+			"oneOf": []any{
+				map[string]any{"type": "string"},
+				map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 1},
+			},
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int:
+		return map[string]any{"type": "integer"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaType(t.Elem(), usage)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaType(t.Elem(), usage)}
+	case reflect.Struct:
+		props := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			prop := schemaType(t.Field(i).Type, usage)
+			if d, ok := usage[key]; ok {
+				prop["description"] = d
+			}
+			props[key] = prop
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	}
+	panic(fmt.Sprintf("no JSON Schema type for %s", t))
+}
+
+// setupSchema sets up "livereload schema", which prints the JSON Schema of
+// the config file.
+func setupSchema(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		return runSchema()
+	}
+}
+
+func runSchema() error {
+	out, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}